@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var entriesBucket = []byte("entries")
+
+// BoltStore keeps entries in a single bolt database file so they survive restarts
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens, or creates, the database file at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(entriesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) Get(key string) (Entry, bool, error) {
+	var entry Entry
+	var found bool
+	err := b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(entriesBucket).Get([]byte(key))
+		if value == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(value, &entry)
+	})
+	if err != nil {
+		return Entry{}, false, err
+	}
+	return entry, found, nil
+}
+
+func (b *BoltStore) Set(key string, entry Entry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(entriesBucket).Put([]byte(key), value)
+	})
+}
+
+func (b *BoltStore) Delete(key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(entriesBucket).Delete([]byte(key))
+	})
+}
+
+func (b *BoltStore) Keys() ([]string, error) {
+	var keys []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(entriesBucket).ForEach(func(key, _ []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+	})
+	return keys, err
+}
+
+func (b *BoltStore) Clear() error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(entriesBucket); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return err
+		}
+		_, err := tx.CreateBucket(entriesBucket)
+		return err
+	})
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
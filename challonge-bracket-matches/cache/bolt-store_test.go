@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	givenEntry := Entry{
+		TournamentsAndParticipants: []models.TournamentParticipants{
+			{GameName: "test", TournamentID: "1", Participant: map[string]string{"1": "testName1"}},
+		},
+		TimeStamp: time.Date(2023, 11, 25, 14, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("It should return entries that were set before a restart", func(t *testing.T) {
+		// Given
+		store, err := NewBoltStore(path)
+		require.NoError(t, err)
+		require.NoError(t, store.Set("2023-11-25", givenEntry))
+		require.NoError(t, store.Close())
+		// When
+		store, err = NewBoltStore(path)
+		require.NoError(t, err)
+		defer store.Close()
+		gotEntry, ok, err := store.Get("2023-11-25")
+		// Then
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, givenEntry.TournamentsAndParticipants, gotEntry.TournamentsAndParticipants)
+		assert.True(t, givenEntry.TimeStamp.Equal(gotEntry.TimeStamp))
+	})
+
+	t.Run("It should report missing and cleared entries", func(t *testing.T) {
+		// Given
+		store, err := NewBoltStore(path)
+		require.NoError(t, err)
+		defer store.Close()
+		require.NoError(t, store.Set("2023-11-25", givenEntry))
+		// When
+		require.NoError(t, store.Clear())
+		// Then
+		_, ok, err := store.Get("2023-11-25")
+		require.NoError(t, err)
+		assert.False(t, ok)
+		_, ok, err = store.Get("2006-01-02")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestBoltStoreDelete(t *testing.T) {
+	// Given
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.Set("2023-11-25", Entry{}))
+	require.NoError(t, store.Set("2006-01-02", Entry{}))
+	// When
+	require.NoError(t, store.Delete("2023-11-25"))
+	// Then
+	keys, err := store.Keys()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2006-01-02"}, keys)
+}
+
+func TestCacheWithBoltStore(t *testing.T) {
+	// Given
+	path := filepath.Join(t.TempDir(), "cache.db")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mockFetchData := &stubFetchData{
+		tournaments:  map[string]string{"1": "test"},
+		participants: map[string]map[string]string{"1": {"1": "testName1"}},
+	}
+	store, err := NewBoltStore(path)
+	require.NoError(t, err)
+	require.NoError(t, NewCache(time.Minute, time.Hour, logger, WithStore(store)).UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+	require.NoError(t, store.Close())
+	// When
+	store, err = NewBoltStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	restartedCache := NewCache(time.Minute, time.Hour, logger, WithStore(store))
+	// Then
+	assert.False(t, restartedCache.IsCacheEmpty(models.DateFilter("2006-01-02")))
+	assert.False(t, restartedCache.ShouldUpdate(models.DateFilter("2006-01-02")))
+	assert.Equal(t, []models.TournamentParticipants{
+		{GameName: "test", TournamentID: "1", Participant: map[string]string{"1": "testName1"}},
+	}, restartedCache.GetData(models.DateFilter("2006-01-02"), nil))
+}
@@ -1,8 +1,11 @@
 package cache
 
 import (
+	"context"
 	"log/slog"
+	"maps"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,62 +13,311 @@ import (
 	"github.com/MarcBernstein0/pending-matches/models"
 )
 
-type cacheData struct {
-	tournamentsAndParticipants []models.TournamentParticipants
-	timeStamp                  time.Time
-}
-
+// Cache is safe for concurrent use. Network calls are made without holding its lock
 type Cache struct {
-	data             map[string]cacheData
+	mu               sync.RWMutex
+	store            Store
 	updateCacheTimer time.Duration
 	clearCacheTimer  time.Duration
+	maxStaleness     time.Duration
+	rosterRefresh    time.Duration
 	lastClearCache   time.Time
 	logger           *slog.Logger
+	refreshes        refreshGroup
+	matches          matchCache
+	rosters          rosterCache
+}
+
+// Option configures optional Cache behaviour
+type Option func(*Cache)
+
+// EntryInfo summarises what the cache holds for a single tournament filter
+type EntryInfo struct {
+	Date        string                  `json:"date"`
+	Filter      models.TournamentFilter `json:"filter"`
+	TimeStamp   time.Time               `json:"time_stamp"`
+	AgeSeconds  int                     `json:"age_seconds"`
+	Tournaments int                     `json:"tournaments"`
+	FetchErrors int                     `json:"fetch_errors"`
+}
+
+// DefaultMaxStaleness is how old data can get before requests stop being served from it while it refreshes
+const DefaultMaxStaleness = 30 * time.Minute
+
+// DefaultRosterRefresh is how often the participants of a tournament already in the cache are fetched again,
+// rosters rarely change once brackets have started
+const DefaultRosterRefresh = 30 * time.Minute
+
+// WithStore keeps the cache's entries in store instead of in memory
+func WithStore(store Store) Option {
+	return func(c *Cache) {
+		c.store = store
+	}
 }
 
-func NewCache(cacheTimer, clearCacheTimer time.Duration, logger *slog.Logger) *Cache {
-	return &Cache{
-		data:             map[string]cacheData{},
+// WithMaxStaleness overrides DefaultMaxStaleness
+func WithMaxStaleness(maxStaleness time.Duration) Option {
+	return func(c *Cache) {
+		c.maxStaleness = maxStaleness
+	}
+}
+
+func NewCache(cacheTimer, clearCacheTimer time.Duration, logger *slog.Logger, opts ...Option) *Cache {
+	c := &Cache{
+		store:            NewMemoryStore(),
 		updateCacheTimer: cacheTimer,
 		clearCacheTimer:  clearCacheTimer,
+		maxStaleness:     DefaultMaxStaleness,
+		rosterRefresh:    DefaultRosterRefresh,
 		lastClearCache:   time.Now(),
 		logger:           logger,
+		matches:          matchCache{ttl: DefaultMatchTTL},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithRefreshTimeout bounds every fetch the cache makes, from the tournaments to the last participant, by timeout
+func WithRefreshTimeout(timeout time.Duration) Option {
+	return func(c *Cache) {
+		c.refreshes.timeout = timeout
+		c.matches.refreshes.timeout = timeout
+		c.rosters.refreshes.timeout = timeout
+	}
+}
+
+// WithRosterRefresh overrides DefaultRosterRefresh
+func WithRosterRefresh(rosterRefresh time.Duration) Option {
+	return func(c *Cache) {
+		c.rosterRefresh = rosterRefresh
 	}
 }
 
-func (c *Cache) UpdateCache(date string, fetchData challongebracketmatches.FetchData) error {
+// UpdateCache fetches the tournaments and participants matching filter. Concurrent calls for the same filter share a single fetch
+func (c *Cache) UpdateCache(ctx context.Context, filter models.TournamentFilter, fetchData challongebracketmatches.FetchData) error {
+	return c.refreshes.do(ctx, filter.Key(), func(ctx context.Context) error {
+		return c.updateCache(ctx, filter, fetchData)
+	})
+}
+
+// refreshStale is UpdateCache for callers that found the data for filter stale or missing. Its age is checked again once
+// the shared refresh starts, so a caller that read it just before another refresh finished doesn't fetch it a second time
+func (c *Cache) refreshStale(ctx context.Context, filter models.TournamentFilter, fetchData challongebracketmatches.FetchData) error {
+	return c.refreshes.do(ctx, filter.Key(), func(ctx context.Context) error {
+		if age, ok := c.age(filter); ok && age < c.updateCacheTimer {
+			return nil
+		}
+		return c.updateCache(ctx, filter, fetchData)
+	})
+}
+
+// updateCache only fetches the participants of tournaments that are new or whose roster is older than the roster refresh,
+// and drops the tournaments that no longer match filter
+func (c *Cache) updateCache(ctx context.Context, filter models.TournamentFilter, fetchData challongebracketmatches.FetchData) error {
 	c.logger.Info("Fetching tournaments") // TODO: Replace print with logging
-	tournaments, err := fetchData.FetchTournaments(date)
+	key := filter.Key()
+	tournaments, err := fetchData.FetchTournaments(ctx, filter)
 	if err != nil {
 		return err
 	}
 
 	if len(tournaments) == 0 {
-		return nil
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.store.Delete(key)
 	}
 
-	c.logger.Info("Fetching participants") // TODO: Replace print with logging
-	listTournamentParticipants, err := c.getParticipantsConcurrently(tournaments, fetchData)
-	if err != nil {
+	c.mu.RLock()
+	previous, _ := c.entry(key)
+	c.mu.RUnlock()
+
+	var listTournamentParticipants []models.TournamentParticipants
+	rosterTimeStamps := map[string]time.Time{}
+	toFetch := map[string]string{}
+	for tournamentId, summary := range tournaments {
+		fetchedAt := previous.RosterTimeStamps[tournamentId]
+		if tournament, ok := findTournament(previous, tournamentId); ok && time.Since(fetchedAt) < c.rosterRefresh {
+			tournament.GameName = summary.GameName
+			listTournamentParticipants = append(listTournamentParticipants, tournament)
+			rosterTimeStamps[tournamentId] = fetchedAt
+			continue
+		}
+		toFetch[tournamentId] = summary.GameName
+	}
+
+	c.logger.Info("Fetching participants", "tournaments", len(toFetch), "unchanged", len(listTournamentParticipants))
+	fetched, fetchErrors := c.getParticipantsConcurrently(ctx, toFetch, fetchData)
+	// a cancelled or timed out refresh keeps the entry as it was instead of storing what it got through
+	if err := ctx.Err(); err != nil {
 		return err
 	}
+	if len(fetched) == 0 && len(listTournamentParticipants) == 0 {
+		return fetchErrors[0]
+	}
+	now := time.Now()
+	for _, tournament := range fetched {
+		listTournamentParticipants = append(listTournamentParticipants, tournament)
+		rosterTimeStamps[tournament.TournamentID] = now
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// keep serving the last known participants of tournaments that failed this time, retrying them on the next update
+	var stillFailing []challongebracketmatches.TournamentError
+	for _, fetchErr := range fetchErrors {
+		c.logger.Error("Error fetching participants", "tournament", fetchErr.TournamentID, "error", fetchErr.Err)
+		if previous, ok := c.findTournament(key, fetchErr.TournamentID); ok {
+			listTournamentParticipants = append(listTournamentParticipants, previous)
+			continue
+		}
+		stillFailing = append(stillFailing, fetchErr)
+	}
 
 	c.logger.Info("Cache is updating") // TODO: Replace print with logging
-	c.data[date] = cacheData{
-		tournamentsAndParticipants: listTournamentParticipants,
-		timeStamp:                  time.Now(),
+	return c.store.Set(key, Entry{
+		Tournaments:                tournaments,
+		TournamentsAndParticipants: listTournamentParticipants,
+		RosterTimeStamps:           rosterTimeStamps,
+		FetchErrors:                stillFailing,
+		TimeStamp:                  now,
+		Filter:                     filter,
+	})
+}
+
+// addParticipants adds participants to the roster of tournamentId in every entry holding it
+func (c *Cache) addParticipants(tournamentId string, participants map[string]string) {
+	c.rosters.add(tournamentId, participants)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys, err := c.store.Keys()
+	if err != nil {
+		c.logger.Error("Error reading from cache store", "error", err)
+		return
 	}
-	return nil
+	for _, key := range keys {
+		data, ok := c.entry(key)
+		if !ok {
+			continue
+		}
+		index := slices.IndexFunc(data.TournamentsAndParticipants, func(tournament models.TournamentParticipants) bool {
+			return tournament.TournamentID == tournamentId
+		})
+		if index < 0 {
+			continue
+		}
+		// the slice and map may be shared with readers of the previous entry
+		data.TournamentsAndParticipants = slices.Clone(data.TournamentsAndParticipants)
+		roster := maps.Clone(data.TournamentsAndParticipants[index].Participant)
+		if roster == nil {
+			roster = map[string]string{}
+		}
+		maps.Copy(roster, participants)
+		data.TournamentsAndParticipants[index].Participant = roster
+		if err := c.store.Set(key, data); err != nil {
+			c.logger.Error("Error writing to cache store", "key", key, "error", err)
+		}
+	}
+}
+
+// expireRoster makes the next update of every entry holding tournamentId fetch its participants again
+func (c *Cache) expireRoster(tournamentId string) {
+	c.rosters.delete(tournamentId)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys, err := c.store.Keys()
+	if err != nil {
+		c.logger.Error("Error reading from cache store", "error", err)
+		return
+	}
+	for _, key := range keys {
+		data, ok := c.entry(key)
+		if !ok {
+			continue
+		}
+		if _, ok := data.RosterTimeStamps[tournamentId]; !ok {
+			continue
+		}
+		// the map may be shared with readers of the previous entry
+		data.RosterTimeStamps = maps.Clone(data.RosterTimeStamps)
+		delete(data.RosterTimeStamps, tournamentId)
+		if err := c.store.Set(key, data); err != nil {
+			c.logger.Error("Error writing to cache store", "key", key, "error", err)
+		}
+	}
+}
+
+// EnsureData makes sure there is usable data for filter and returns how old it is and whether it is stale.
+// Stale data younger than the max staleness is returned straight away while it is refreshed in the
+// background, so a failed refresh keeps serving it. Missing or older data is refreshed before returning
+func (c *Cache) EnsureData(ctx context.Context, filter models.TournamentFilter, fetchData challongebracketmatches.FetchData) (time.Duration, bool, error) {
+	age, ok := c.age(filter)
+	switch {
+	case ok && age < c.updateCacheTimer:
+		return age, false, nil
+	case ok && age < c.maxStaleness:
+		c.refreshAsync(filter, fetchData)
+		return age, true, nil
+	}
+
+	if err := c.refreshStale(ctx, filter, fetchData); err != nil {
+		return age, ok, err
+	}
+	age, _ = c.age(filter)
+	return age, false, nil
+}
+
+func (c *Cache) refreshAsync(filter models.TournamentFilter, fetchData challongebracketmatches.FetchData) {
+	if c.refreshes.inFlight(filter.Key()) > 0 {
+		return
+	}
+	go func() {
+		if err := c.refreshStale(context.Background(), filter, fetchData); err != nil {
+			c.logger.Error("Background cache refresh failed, serving stale data", "filter", filter.Key(), "error", err)
+		}
+	}()
+}
+
+// age returns how long ago the data for filter was fetched, false if there is none
+func (c *Cache) age(filter models.TournamentFilter) (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data, ok := c.entry(filter.Key())
+	if !ok || len(data.TournamentsAndParticipants) == 0 {
+		return 0, false
+	}
+	return time.Since(data.TimeStamp), true
+}
+
+// entry reads key from the store, treating a failed read as a miss. It must be called with c.mu held
+func (c *Cache) entry(key string) (Entry, bool) {
+	data, ok, err := c.store.Get(key)
+	if err != nil {
+		c.logger.Error("Error reading from cache store", "key", key, "error", err)
+		return Entry{}, false
+	}
+	return data, ok
 }
 
-func (c *Cache) GetData(date string, gamesList []string) []models.TournamentParticipants {
+func (c *Cache) GetData(filter models.TournamentFilter, gamesList []string) []models.TournamentParticipants {
 	c.logger.Info("Getting data from cache")
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data, _ := c.entry(filter.Key())
 	if len(gamesList) == 0 {
-		return c.data[date].tournamentsAndParticipants
+		return slices.Clone(data.TournamentsAndParticipants)
 	}
 
 	ret := []models.TournamentParticipants{}
-	for _, tournament := range c.data[date].tournamentsAndParticipants {
+	for _, tournament := range data.TournamentsAndParticipants {
 		if slices.Contains(gamesList, tournament.GameName) {
 			ret = append(ret, tournament)
 		}
@@ -73,78 +325,275 @@ func (c *Cache) GetData(date string, gamesList []string) []models.TournamentPart
 	return ret
 }
 
-func (c *Cache) ShouldUpdate(date string) bool {
-	if data, ok := c.data[date]; ok {
-		timeSince := time.Since(data.timeStamp)
+// GetTournaments returns the tournaments, filtered by gamesList, cached for filter ordered by game and name
+func (c *Cache) GetTournaments(filter models.TournamentFilter, gamesList []string) []models.TournamentSummary {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data, _ := c.entry(filter.Key())
+	summaries := maps.Clone(data.Tournaments)
+	if summaries == nil {
+		summaries = map[string]models.TournamentSummary{}
+	}
+	for _, tournament := range data.TournamentsAndParticipants {
+		summary, ok := summaries[tournament.TournamentID]
+		if !ok {
+			// entries stored before summaries were kept only know the game of each tournament
+			summary = models.TournamentSummary{Id: tournament.TournamentID, GameName: tournament.GameName}
+		}
+		if summary.Participants == 0 {
+			summary.Participants = len(tournament.Participant)
+		}
+		summaries[tournament.TournamentID] = summary
+	}
+
+	ret := []models.TournamentSummary{}
+	for _, summary := range summaries {
+		if len(gamesList) == 0 || slices.Contains(gamesList, summary.GameName) {
+			ret = append(ret, summary)
+		}
+	}
+	slices.SortFunc(ret, func(a, b models.TournamentSummary) int {
+		if n := strings.Compare(a.GameName, b.GameName); n != 0 {
+			return n
+		}
+		if n := strings.Compare(a.Name, b.Name); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+	return ret
+}
+
+// GetErrors returns the tournaments, filtered by gamesList, whose participants could not be fetched on the last update
+func (c *Cache) GetErrors(filter models.TournamentFilter, gamesList []string) []challongebracketmatches.TournamentError {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data, _ := c.entry(filter.Key())
+	ret := []challongebracketmatches.TournamentError{}
+	for _, fetchErr := range data.FetchErrors {
+		if len(gamesList) == 0 || slices.Contains(gamesList, fetchErr.GameName) {
+			ret = append(ret, fetchErr)
+		}
+	}
+	return ret
+}
+
+func (c *Cache) ShouldUpdate(filter models.TournamentFilter) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if data, ok := c.entry(filter.Key()); ok {
+		timeSince := time.Since(data.TimeStamp)
 		return timeSince >= c.updateCacheTimer
 	}
 	return true
 }
 
-func (c *Cache) IsCacheEmptyAtDate(date string) bool {
-	if data, ok := c.data[date]; ok {
-		return len(data.tournamentsAndParticipants) == 0
+func (c *Cache) IsCacheEmpty(filter models.TournamentFilter) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if data, ok := c.entry(filter.Key()); ok {
+		return len(data.TournamentsAndParticipants) == 0
 	}
 	return true
 }
 
 func (c *Cache) ShouldClearCacheData() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	timeSince := time.Since(c.lastClearCache)
 	return timeSince >= c.clearCacheTimer
 }
 
 func (c *Cache) ClearCache() {
-	c.data = map[string]cacheData{}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.store.Clear(); err != nil {
+		c.logger.Error("Error clearing cache store", "error", err)
+	}
+	c.matches.clear()
+	c.rosters.clear()
 	c.lastClearCache = time.Now()
 }
 
-func (c *Cache) getParticipantsConcurrently(tournaments map[string]string, fetchData challongebracketmatches.FetchData) ([]models.TournamentParticipants, error) {
+// Entries describes every filter held by the cache, oldest date first
+func (c *Cache) Entries() ([]EntryInfo, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys, err := c.store.Keys()
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(keys)
+
+	entries := make([]EntryInfo, 0, len(keys))
+	for _, key := range keys {
+		if data, ok := c.entry(key); ok {
+			entries = append(entries, entryInfo(key, data))
+		}
+	}
+	return entries, nil
+}
+
+// EntryAt describes what the cache holds for filter, false if there is nothing
+func (c *Cache) EntryAt(filter models.TournamentFilter) (EntryInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data, ok := c.entry(filter.Key())
+	if !ok {
+		return EntryInfo{}, false
+	}
+	return entryInfo(filter.Key(), data), true
+}
+
+// InvalidateDate drops everything cached for filters created after date so the next request for them fetches them again
+func (c *Cache) InvalidateDate(date string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys, err := c.store.Keys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		data, ok := c.entry(key)
+		if !ok || entryFilter(key, data).CreatedAfter != date {
+			continue
+		}
+		if err := c.store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateTournament drops the participants and matches cached for a tournament and expires every entry
+// that included it, so the next request for those filters fetches their tournaments again.
+// It returns false if the tournament was not cached
+func (c *Cache) InvalidateTournament(tournamentId string) (bool, error) {
+	found := c.matches.delete(tournamentId)
+	found = c.rosters.delete(tournamentId) || found
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys, err := c.store.Keys()
+	if err != nil {
+		return found, err
+	}
+	for _, key := range keys {
+		data, ok := c.entry(key)
+		if !ok {
+			continue
+		}
+		index := slices.IndexFunc(data.TournamentsAndParticipants, func(tournament models.TournamentParticipants) bool {
+			return tournament.TournamentID == tournamentId
+		})
+		if index < 0 {
+			continue
+		}
+		found = true
+		data.TournamentsAndParticipants = slices.Delete(slices.Clone(data.TournamentsAndParticipants), index, index+1)
+		data.Tournaments = maps.Clone(data.Tournaments)
+		delete(data.Tournaments, tournamentId)
+		data.TimeStamp = time.Time{}
+		if err := c.store.Set(key, data); err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+func entryInfo(key string, data Entry) EntryInfo {
+	filter := entryFilter(key, data)
+	return EntryInfo{
+		Date:        filter.CreatedAfter,
+		Filter:      filter,
+		TimeStamp:   data.TimeStamp,
+		AgeSeconds:  int(time.Since(data.TimeStamp).Seconds()),
+		Tournaments: len(data.TournamentsAndParticipants),
+		FetchErrors: len(data.FetchErrors),
+	}
+}
+
+// entryFilter returns the filter data was fetched for. Entries stored before filters were kept are under a date key
+func entryFilter(key string, data Entry) models.TournamentFilter {
+	if data.Filter.CreatedAfter == "" {
+		return models.DateFilter(key)
+	}
+	return data.Filter
+}
+
+// findTournament must be called with c.mu held
+func (c *Cache) findTournament(key, tournamentId string) (models.TournamentParticipants, bool) {
+	data, _ := c.entry(key)
+	return findTournament(data, tournamentId)
+}
+
+func findTournament(data Entry, tournamentId string) (models.TournamentParticipants, bool) {
+	for _, tournament := range data.TournamentsAndParticipants {
+		if tournament.TournamentID == tournamentId {
+			return tournament, true
+		}
+	}
+	return models.TournamentParticipants{}, false
+}
+
+// getParticipantsConcurrently fetches the participants of every tournament, returning the ones that succeeded
+// alongside an error for each one that did not
+func (c *Cache) getParticipantsConcurrently(ctx context.Context, tournaments map[string]string, fetchData challongebracketmatches.FetchData) ([]models.TournamentParticipants, []challongebracketmatches.TournamentError) {
 	var tournamentParticipants []models.TournamentParticipants
+	var fetchErrors []challongebracketmatches.TournamentError
 
+	// buffered so no goroutine is left blocked on send
 	chanResponse := make(chan struct {
-		tournamentParticipant *models.TournamentParticipants
-		err                   error
-	})
+		tournamentParticipant models.TournamentParticipants
+		err                   *challongebracketmatches.TournamentError
+	}, len(tournaments))
 	var wg sync.WaitGroup
 	for key, val := range tournaments {
 		wg.Add(1)
-		go func(tournamentId, tournamentGame string, chanResponse chan struct {
-			tournamentParticipant *models.TournamentParticipants
-			err                   error
-		}, wg *sync.WaitGroup) {
+		go func(tournamentId, tournamentGame string) {
 			defer wg.Done()
-			participants, err := fetchData.FetchParticipants(tournamentId, tournamentGame)
+			participants, err := fetchData.FetchParticipants(ctx, tournamentId, tournamentGame)
+			result := struct {
+				tournamentParticipant models.TournamentParticipants
+				err                   *challongebracketmatches.TournamentError
+			}{
+				tournamentParticipant: participants,
+			}
 			if err != nil {
-				chanResponse <- struct {
-					tournamentParticipant *models.TournamentParticipants
-					err                   error
-				}{
-					tournamentParticipant: nil,
-					err:                   err,
+				result.err = &challongebracketmatches.TournamentError{
+					TournamentID: tournamentId,
+					GameName:     tournamentGame,
+					Err:          err,
 				}
-				return
-			}
-			chanResponse <- struct {
-				tournamentParticipant *models.TournamentParticipants
-				err                   error
-			}{
-				tournamentParticipant: &participants,
-				err:                   nil,
 			}
-		}(key, val, chanResponse, &wg)
+			chanResponse <- result
+		}(key, val)
 	}
 
-	go func() {
-		wg.Wait()
-		close(chanResponse)
-	}()
+	wg.Wait()
+	close(chanResponse)
 
 	for getParticipantResult := range chanResponse {
 		if getParticipantResult.err != nil {
-			return nil, getParticipantResult.err
+			fetchErrors = append(fetchErrors, *getParticipantResult.err)
+			continue
 		}
-		tournamentParticipants = append(tournamentParticipants, *getParticipantResult.tournamentParticipant)
+		tournamentParticipants = append(tournamentParticipants, getParticipantResult.tournamentParticipant)
 	}
 
-	return tournamentParticipants, nil
+	slices.SortFunc(fetchErrors, func(a, b challongebracketmatches.TournamentError) int {
+		return strings.Compare(a.TournamentID, b.TournamentID)
+	})
+
+	return tournamentParticipants, fetchErrors
 }
@@ -1,20 +1,26 @@
 package cache
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
 	"github.com/MarcBernstein0/pending-matches/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var server *httptest.Server
@@ -54,14 +60,16 @@ func TestMain(m *testing.M) {
 func TestCreateCache(t *testing.T) {
 	// Given
 	givenCache := &Cache{
-		data:             map[string]cacheData{},
+		store:            NewMemoryStore(),
 		updateCacheTimer: 5 * time.Minute,
 		clearCacheTimer:  5 * time.Minute,
+		maxStaleness:     DefaultMaxStaleness,
 	}
 	// When
 	mockCache := NewCache(5*time.Minute, 5*time.Minute, slog.Default())
 	// Then
-	assert.Equal(t, givenCache.data, mockCache.data)
+	assert.Equal(t, givenCache.store, mockCache.store)
+	assert.Equal(t, givenCache.maxStaleness, mockCache.maxStaleness)
 	assert.Equal(t, givenCache.updateCacheTimer, mockCache.updateCacheTimer)
 	assert.Equal(t, givenCache.clearCacheTimer, mockCache.clearCacheTimer)
 
@@ -80,30 +88,30 @@ func TestUpdateCache(t *testing.T) {
 		{
 			name: "response not ok",
 			mockRequestValues: models.RequestValues{
-				Date:     "2022-07-16",
+				Filter:   models.DateFilter("2022-07-16"),
 				GameList: []string{},
 			},
 			mockFetchData: challongebracketmatches.New(server.URL, "bad api key", http.DefaultClient, 5*time.Second),
 			wantData:      nil,
-			wantErr:       fmt.Errorf("%w. %s", challongebracketmatches.ErrResponseNotOK, http.StatusText(http.StatusUnauthorized)),
+			wantErr:       &challongebracketmatches.APIError{StatusCode: http.StatusUnauthorized, Endpoint: "/tournaments.json"},
 		},
 		{
 			name: "response not ok but no tournaments",
 			mockRequestValues: models.RequestValues{
-				Date:     "2022-07-16",
+				Filter:   models.DateFilter("2022-07-16"),
 				GameList: []string{},
 			},
-			mockFetchData: challongebracketmatches.New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second),
+			mockFetchData: challongebracketmatches.New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second, challongebracketmatches.WithPageSize(2)),
 			wantData:      []models.TournamentParticipants{},
 			wantErr:       nil,
 		},
 		{
 			name: "response ok",
 			mockRequestValues: models.RequestValues{
-				Date:     "2023-11-25",
+				Filter:   models.DateFilter("2023-11-25"),
 				GameList: []string{},
 			},
-			mockFetchData: challongebracketmatches.New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second),
+			mockFetchData: challongebracketmatches.New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second, challongebracketmatches.WithPageSize(2)),
 			wantData: []models.TournamentParticipants{
 				{
 					GameName:     "test5",
@@ -187,12 +195,12 @@ func TestUpdateCache(t *testing.T) {
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			// When
-			gotErr := mockCache.UpdateCache(tc.mockRequestValues.Date, tc.mockFetchData)
+			gotErr := mockCache.UpdateCache(context.Background(), tc.mockRequestValues.Filter, tc.mockFetchData)
 			// Then
 			if tc.wantErr != nil {
 				assert.EqualError(t, gotErr, tc.wantErr.Error())
 			} else {
-				assert.ElementsMatch(t, tc.wantData, mockCache.GetData(tc.mockRequestValues.Date, tc.mockRequestValues.GameList))
+				assert.ElementsMatch(t, tc.wantData, mockCache.GetData(tc.mockRequestValues.Filter, tc.mockRequestValues.GameList))
 				assert.NoError(t, gotErr)
 			}
 		})
@@ -200,43 +208,534 @@ func TestUpdateCache(t *testing.T) {
 	}
 }
 
+func TestUpdateCacheCancelled(t *testing.T) {
+	// Given
+	mockCache := NewCache(5*time.Minute, 5*time.Hour, slog.Default())
+	mockFetchData := &blockingFetchData{
+		tournaments: map[string]string{"1": "test", "2": "test2", "3": "test3"},
+		started:     make(chan struct{}, 3),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- mockCache.UpdateCache(ctx, models.DateFilter("2006-01-02"), mockFetchData)
+	}()
+	for i := 0; i < 3; i++ {
+		<-mockFetchData.started
+	}
+	// When
+	cancel()
+	// Then
+	select {
+	case gotErr := <-done:
+		assert.True(t, errors.Is(gotErr, context.Canceled))
+	case <-time.After(time.Second):
+		t.Fatal("UpdateCache did not return after its context was cancelled")
+	}
+	assert.Eventually(t, func() bool {
+		return mockFetchData.inFlight.Load() == 0
+	}, time.Second, time.Millisecond)
+	assert.True(t, mockCache.IsCacheEmpty(models.DateFilter("2006-01-02")))
+}
+
+func TestUpdateCacheTimeout(t *testing.T) {
+	t.Run("It should bound the whole refresh by the refresh timeout", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(5*time.Minute, 5*time.Hour, slog.Default(), WithRefreshTimeout(50*time.Millisecond))
+		mockFetchData := &slowFetchData{
+			stubFetchData: stubFetchData{
+				tournaments:  map[string]string{"1": "test"},
+				participants: map[string]map[string]string{"1": {"1": "testName1"}},
+			},
+			delay: 30 * time.Millisecond,
+		}
+		// When
+		gotErr := mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		assert.True(t, errors.Is(gotErr, context.DeadlineExceeded))
+		assert.True(t, mockCache.IsCacheEmpty(models.DateFilter("2006-01-02")))
+	})
+
+	t.Run("It should keep a caller deadline shorter than the refresh timeout", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(5*time.Minute, 5*time.Hour, slog.Default(), WithRefreshTimeout(time.Minute))
+		mockFetchData := &slowFetchData{
+			stubFetchData: stubFetchData{
+				tournaments:  map[string]string{"1": "test"},
+				participants: map[string]map[string]string{"1": {"1": "testName1"}},
+			},
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		wantDeadline, _ := ctx.Deadline()
+		// When
+		gotErr := mockCache.UpdateCache(ctx, models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		assert.NoError(t, gotErr)
+		assert.Equal(t, wantDeadline, mockFetchData.deadline)
+	})
+}
+
+func TestUpdateCachePartial(t *testing.T) {
+	mockFetchData := &stubFetchData{
+		tournaments: map[string]string{"1": "test", "2": "test2"},
+		participants: map[string]map[string]string{
+			"1": {"1": "testName1"},
+			"2": {"2": "testName2"},
+		},
+		failing: map[string]error{"2": challongebracketmatches.ErrServerProblem},
+	}
+
+	t.Run("It should keep the tournaments that succeeded and record the ones that failed", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(5*time.Minute, 5*time.Hour, slog.Default())
+		// When
+		gotErr := mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		assert.NoError(t, gotErr)
+		assert.Equal(t, []models.TournamentParticipants{
+			{GameName: "test", TournamentID: "1", Participant: map[string]string{"1": "testName1"}},
+		}, mockCache.GetData(models.DateFilter("2006-01-02"), nil))
+		gotErrors := mockCache.GetErrors(models.DateFilter("2006-01-02"), nil)
+		assert.Len(t, gotErrors, 1)
+		assert.Equal(t, "2", gotErrors[0].TournamentID)
+		assert.True(t, errors.Is(gotErrors[0], challongebracketmatches.ErrServerProblem))
+		assert.Empty(t, mockCache.GetErrors(models.DateFilter("2006-01-02"), []string{"test"}))
+	})
+
+	t.Run("It should keep the previous participants of a tournament that failed", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(5*time.Minute, 5*time.Hour, slog.Default())
+		previous := models.TournamentParticipants{GameName: "test2", TournamentID: "2", Participant: map[string]string{"2": "oldName2"}}
+		mockCache.store.Set("2006-01-02", Entry{TournamentsAndParticipants: []models.TournamentParticipants{previous}})
+		// When
+		gotErr := mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		assert.NoError(t, gotErr)
+		assert.ElementsMatch(t, []models.TournamentParticipants{
+			{GameName: "test", TournamentID: "1", Participant: map[string]string{"1": "testName1"}},
+			previous,
+		}, mockCache.GetData(models.DateFilter("2006-01-02"), nil))
+		assert.Empty(t, mockCache.GetErrors(models.DateFilter("2006-01-02"), nil))
+	})
+
+	t.Run("It should return an error when every tournament failed", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(5*time.Minute, 5*time.Hour, slog.Default())
+		allFailing := &stubFetchData{
+			tournaments: map[string]string{"2": "test2"},
+			failing:     map[string]error{"2": challongebracketmatches.ErrServerProblem},
+		}
+		// When
+		gotErr := mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), allFailing)
+		// Then
+		assert.True(t, errors.Is(gotErr, challongebracketmatches.ErrServerProblem))
+		assert.True(t, mockCache.IsCacheEmpty(models.DateFilter("2006-01-02")))
+	})
+}
+
+func TestUpdateCacheIncremental(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	newFetchData := func() *rosterFetchData {
+		return &rosterFetchData{
+			stubFetchData: stubFetchData{
+				tournaments:  map[string]string{"1": "test", "2": "test2"},
+				participants: map[string]map[string]string{"1": {"1": "testName1"}, "2": {"2": "testName2"}, "3": {"3": "testName3"}},
+			},
+			participantCalls: map[string]int{},
+		}
+	}
+
+	t.Run("It should only fetch the participants of new tournaments", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := newFetchData()
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		mockFetchData.stubFetchData.tournaments = map[string]string{"1": "test", "2": "test2", "3": "test3"}
+		// When
+		err := mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"1": 1, "2": 1, "3": 1}, mockFetchData.calls())
+		assert.Len(t, mockCache.GetData(models.DateFilter("2006-01-02"), nil), 3)
+	})
+
+	t.Run("It should drop tournaments that are no longer in progress", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := newFetchData()
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		mockFetchData.stubFetchData.tournaments = map[string]string{"1": "test"}
+		// When
+		err := mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, []models.TournamentParticipants{
+			{GameName: "test", TournamentID: "1", Participant: map[string]string{"1": "testName1"}},
+		}, mockCache.GetData(models.DateFilter("2006-01-02"), nil))
+		assert.Equal(t, map[string]int{"1": 1, "2": 1}, mockFetchData.calls())
+	})
+
+	t.Run("It should fetch rosters again once the roster refresh has passed", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger, WithRosterRefresh(time.Nanosecond))
+		mockFetchData := newFetchData()
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		time.Sleep(time.Millisecond)
+		// When
+		err := mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"1": 2, "2": 2}, mockFetchData.calls())
+	})
+
+	t.Run("It should fetch a roster again once a match references an unknown participant", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := newFetchData()
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		mockFetchData.unknownParticipants = []string{"4"}
+		_, err := mockCache.GetMatches(context.Background(), mockCache.GetData(models.DateFilter("2006-01-02"), []string{"test"})[0], models.DefaultMatchStates, mockFetchData)
+		require.NoError(t, err)
+		// When
+		err = mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"1": 2, "2": 1}, mockFetchData.calls())
+	})
+
+	t.Run("It should add participants resolved while fetching matches to the cached roster", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := newFetchData()
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		mockFetchData.resolvedParticipants = map[string]string{"4": "testName4"}
+		// When
+		_, err := mockCache.GetMatches(context.Background(), mockCache.GetData(models.DateFilter("2006-01-02"), []string{"test"})[0], models.DefaultMatchStates, mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, []models.TournamentParticipants{
+			{GameName: "test", TournamentID: "1", Participant: map[string]string{"1": "testName1", "4": "testName4"}},
+		}, mockCache.GetData(models.DateFilter("2006-01-02"), []string{"test"}))
+		assert.Equal(t, int64(1), mockCache.MatchStats().ParticipantMisses)
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		assert.Equal(t, map[string]int{"1": 1, "2": 1}, mockFetchData.calls())
+	})
+}
+
+// run with -race to catch unsynchronised access
+func TestCacheConcurrentAccess(t *testing.T) {
+	// Given
+	mockCache := NewCache(time.Nanosecond, time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	mockFetchData := challongebracketmatches.New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second, challongebracketmatches.WithPageSize(2))
+	// When
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if mockCache.ShouldClearCacheData() && i%5 == 0 {
+					mockCache.ClearCache()
+				}
+				if mockCache.IsCacheEmpty(models.DateFilter("2023-11-25")) || mockCache.ShouldUpdate(models.DateFilter("2023-11-25")) {
+					assert.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2023-11-25"), mockFetchData))
+				}
+				mockCache.GetData(models.DateFilter("2023-11-25"), []string{"test", "test2"})
+				mockCache.GetErrors(models.DateFilter("2023-11-25"), nil)
+			}
+		}(i)
+	}
+	wg.Wait()
+	// Then
+	assert.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2023-11-25"), mockFetchData))
+	assert.Len(t, mockCache.GetData(models.DateFilter("2023-11-25"), nil), 6)
+}
+
+func TestUpdateCacheDeduplicated(t *testing.T) {
+	const waiters = 10
+
+	t.Run("It should share one fetch between concurrent updates of the same date", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(5*time.Minute, 5*time.Hour, slog.Default())
+		mockFetchData := &countingFetchData{
+			stubFetchData: stubFetchData{
+				tournaments:  map[string]string{"1": "test"},
+				participants: map[string]map[string]string{"1": {"1": "testName1"}},
+			},
+			release: make(chan struct{}),
+		}
+		// When
+		errs := make(chan error, waiters)
+		for i := 0; i < waiters; i++ {
+			go func() {
+				errs <- mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+			}()
+		}
+		assert.Eventually(t, func() bool {
+			return mockCache.refreshes.inFlight("2006-01-02") == waiters
+		}, time.Second, time.Millisecond)
+		close(mockFetchData.release)
+		// Then
+		for i := 0; i < waiters; i++ {
+			assert.NoError(t, <-errs)
+		}
+		assert.Equal(t, int32(1), mockFetchData.tournamentCalls.Load())
+		assert.Len(t, mockCache.GetData(models.DateFilter("2006-01-02"), nil), 1)
+	})
+
+	t.Run("It should keep fetching while at least one caller is still waiting", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(5*time.Minute, 5*time.Hour, slog.Default())
+		mockFetchData := &countingFetchData{
+			stubFetchData: stubFetchData{
+				tournaments:  map[string]string{"1": "test"},
+				participants: map[string]map[string]string{"1": {"1": "testName1"}},
+			},
+			release: make(chan struct{}),
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		leaving := make(chan error)
+		go func() {
+			leaving <- mockCache.UpdateCache(ctx, models.DateFilter("2006-01-02"), mockFetchData)
+		}()
+		staying := make(chan error)
+		go func() {
+			staying <- mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		}()
+		assert.Eventually(t, func() bool {
+			return mockCache.refreshes.inFlight("2006-01-02") == 2
+		}, time.Second, time.Millisecond)
+		// When
+		cancel()
+		assert.True(t, errors.Is(<-leaving, context.Canceled))
+		close(mockFetchData.release)
+		// Then
+		assert.NoError(t, <-staying)
+		assert.Equal(t, int32(1), mockFetchData.tournamentCalls.Load())
+		assert.Len(t, mockCache.GetData(models.DateFilter("2006-01-02"), nil), 1)
+	})
+}
+
+func TestUpdateCacheFilters(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	givenPending := models.TournamentFilter{CreatedAfter: "2006-01-01", CreatedBefore: "2006-01-03", State: models.TournamentStatePending}
+
+	t.Run("It should cache each filter separately", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := &filterFetchData{tournaments: map[string]map[string]string{
+			models.DateFilter("2006-01-01").Key(): {"1": "test"},
+			givenPending.Key():                    {"2": "test2", "3": "test3"},
+		}}
+		// When
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-01"), mockFetchData))
+		require.NoError(t, mockCache.UpdateCache(context.Background(), givenPending, mockFetchData))
+		// Then
+		assert.Len(t, mockCache.GetData(models.DateFilter("2006-01-01"), nil), 1)
+		assert.Len(t, mockCache.GetData(givenPending, nil), 2)
+		entries, err := mockCache.Entries()
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, models.DateFilter("2006-01-01"), entries[0].Filter)
+		assert.Equal(t, givenPending, entries[1].Filter)
+		assert.Equal(t, "2006-01-01", entries[1].Date)
+	})
+
+	t.Run("It should drop every filter starting at an invalidated date", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := &filterFetchData{tournaments: map[string]map[string]string{
+			models.DateFilter("2006-01-01").Key(): {"1": "test"},
+			givenPending.Key():                    {"2": "test2"},
+			models.DateFilter("2006-01-02").Key(): {"3": "test3"},
+		}}
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-01"), mockFetchData))
+		require.NoError(t, mockCache.UpdateCache(context.Background(), givenPending, mockFetchData))
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		// When
+		err := mockCache.InvalidateDate("2006-01-01")
+		// Then
+		require.NoError(t, err)
+		assert.True(t, mockCache.IsCacheEmpty(models.DateFilter("2006-01-01")))
+		assert.True(t, mockCache.IsCacheEmpty(givenPending))
+		assert.False(t, mockCache.IsCacheEmpty(models.DateFilter("2006-01-02")))
+	})
+}
+
+func TestGetTournaments(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mockFetchData := &stubFetchData{
+		tournaments:  map[string]string{"1": "test", "2": "test2", "3": "test"},
+		participants: map[string]map[string]string{"1": {"1": "testName1", "2": "testName2"}},
+		failing:      map[string]error{"3": errors.New("participants failed")},
+	}
+
+	t.Run("It should list every tournament of the requested games, including those whose participants failed", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		// When
+		gotTournaments := mockCache.GetTournaments(models.DateFilter("2006-01-02"), []string{"test"})
+		// Then
+		assert.Equal(t, []models.TournamentSummary{
+			{Id: "1", GameName: "test", Participants: 2},
+			{Id: "3", GameName: "test"},
+		}, gotTournaments)
+	})
+
+	t.Run("It should leave out an invalidated tournament", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		// When
+		_, err := mockCache.InvalidateTournament("2")
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, []models.TournamentSummary{
+			{Id: "1", GameName: "test", Participants: 2},
+			{Id: "3", GameName: "test"},
+		}, mockCache.GetTournaments(models.DateFilter("2006-01-02"), nil))
+	})
+}
+
+func TestEnsureData(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	previous := []models.TournamentParticipants{{GameName: "test", TournamentID: "1", Participant: map[string]string{"1": "oldName1"}}}
+	newFetchData := func(tournamentsErr error) *countingFetchData {
+		mockFetchData := &countingFetchData{
+			stubFetchData: stubFetchData{
+				tournaments:    map[string]string{"1": "test"},
+				tournamentsErr: tournamentsErr,
+				participants:   map[string]map[string]string{"1": {"1": "testName1"}},
+			},
+			release: make(chan struct{}),
+		}
+		close(mockFetchData.release)
+		return mockFetchData
+	}
+
+	t.Run("It should serve fresh data without fetching", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockCache.store.Set("2006-01-02", Entry{TournamentsAndParticipants: previous, TimeStamp: time.Now()})
+		mockFetchData := newFetchData(nil)
+		// When
+		_, stale, err := mockCache.EnsureData(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		assert.NoError(t, err)
+		assert.False(t, stale)
+		assert.Equal(t, int32(0), mockFetchData.tournamentCalls.Load())
+	})
+
+	t.Run("It should serve stale data while refreshing in the background", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger, WithMaxStaleness(time.Hour))
+		mockCache.store.Set("2006-01-02", Entry{TournamentsAndParticipants: previous, TimeStamp: time.Now().Add(-10 * time.Minute)})
+		mockFetchData := newFetchData(nil)
+		// When
+		age, stale, err := mockCache.EnsureData(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		assert.NoError(t, err)
+		assert.True(t, stale)
+		assert.GreaterOrEqual(t, age, 10*time.Minute)
+		assert.Eventually(t, func() bool {
+			data := mockCache.GetData(models.DateFilter("2006-01-02"), nil)
+			return len(data) == 1 && data[0].Participant["1"] == "testName1"
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("It should keep serving stale data when the background refresh fails", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger, WithMaxStaleness(time.Hour))
+		mockCache.store.Set("2006-01-02", Entry{TournamentsAndParticipants: previous, TimeStamp: time.Now().Add(-10 * time.Minute)})
+		mockFetchData := newFetchData(challongebracketmatches.ErrServerProblem)
+		// When
+		_, stale, err := mockCache.EnsureData(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		assert.NoError(t, err)
+		assert.True(t, stale)
+		assert.Eventually(t, func() bool {
+			return mockFetchData.tournamentCalls.Load() == 1 && mockCache.refreshes.inFlight("2006-01-02") == 0
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, previous, mockCache.GetData(models.DateFilter("2006-01-02"), nil))
+	})
+
+	t.Run("It should refresh inline once data is older than the max staleness", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger, WithMaxStaleness(5*time.Minute))
+		mockCache.store.Set("2006-01-02", Entry{TournamentsAndParticipants: previous, TimeStamp: time.Now().Add(-10 * time.Minute)})
+		mockFetchData := newFetchData(nil)
+		// When
+		age, stale, err := mockCache.EnsureData(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		assert.NoError(t, err)
+		assert.False(t, stale)
+		assert.Less(t, age, time.Minute)
+		assert.Equal(t, "testName1", mockCache.GetData(models.DateFilter("2006-01-02"), nil)[0].Participant["1"])
+	})
+
+	t.Run("It should not fetch again when another refresh finished after the data was found stale", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockCache.store.Set("2006-01-02", Entry{TournamentsAndParticipants: previous, TimeStamp: time.Now()})
+		mockFetchData := newFetchData(nil)
+		// When
+		err := mockCache.refreshStale(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		assert.NoError(t, err)
+		assert.Equal(t, int32(0), mockFetchData.tournamentCalls.Load())
+		assert.Equal(t, previous, mockCache.GetData(models.DateFilter("2006-01-02"), nil))
+	})
+
+	t.Run("It should return the error of an inline refresh", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := newFetchData(challongebracketmatches.ErrServerProblem)
+		// When
+		_, _, err := mockCache.EnsureData(context.Background(), models.DateFilter("2006-01-02"), mockFetchData)
+		// Then
+		assert.True(t, errors.Is(err, challongebracketmatches.ErrServerProblem))
+	})
+}
+
 func TestShouldUpdate(t *testing.T) {
 	t.Run("It should return true when the timer has been exceeded", func(t *testing.T) {
 		// Given
 		mockCache := NewCache(2*time.Microsecond, 2*time.Microsecond, slog.Default())
-		mockCache.data["2006-01-02"] = cacheData{}
+		mockCache.store.Set("2006-01-02", Entry{})
 		// When
 		time.Sleep(5 * time.Millisecond)
 		// Then
-		assert.Equal(t, true, mockCache.ShouldUpdate("2006-01-02"))
+		assert.Equal(t, true, mockCache.ShouldUpdate(models.DateFilter("2006-01-02")))
 	})
 
 	t.Run("It should return false when timer has not been exceeded", func(t *testing.T) {
 		// Given
 		mockCache := NewCache(5*time.Microsecond, 5*time.Microsecond, slog.Default())
-		mockCache.data["2006-01-02"] = cacheData{}
+		mockCache.store.Set("2006-01-02", Entry{})
 		// When
 		time.Sleep(2 * time.Millisecond)
 		// Then
-		assert.Equal(t, true, mockCache.ShouldUpdate("2006-01-02"))
+		assert.Equal(t, true, mockCache.ShouldUpdate(models.DateFilter("2006-01-02")))
 	})
 
 	t.Run("It should return true as no data exists at the given date", func(t *testing.T) {
 		// Given
 		mockCache := NewCache(5*time.Microsecond, 5*time.Microsecond, slog.Default())
 		// When
-		res := mockCache.ShouldUpdate("2006-01-02")
+		res := mockCache.ShouldUpdate(models.DateFilter("2006-01-02"))
 		// Then
 		assert.Equal(t, true, res)
 	})
 }
 
-func TestIsCacheEmptyAtDate(t *testing.T) {
+func TestIsCacheEmpty(t *testing.T) {
 	t.Run("It should return false if data exists at a given date", func(t *testing.T) {
 		// Given
 		mockCache := NewCache(5*time.Microsecond, 5*time.Microsecond, slog.Default())
-		mockCache.data["2006-01-02"] = cacheData{
-			tournamentsAndParticipants: []models.TournamentParticipants{
+		mockCache.store.Set("2006-01-02", Entry{
+			TournamentsAndParticipants: []models.TournamentParticipants{
 				{
 					GameName:     "test",
 					TournamentID: "1234",
@@ -246,9 +745,9 @@ func TestIsCacheEmptyAtDate(t *testing.T) {
 					},
 				},
 			},
-		}
+		})
 		// When
-		res := mockCache.IsCacheEmptyAtDate("2006-01-02")
+		res := mockCache.IsCacheEmpty(models.DateFilter("2006-01-02"))
 		// Then
 		assert.Equal(t, false, res)
 	})
@@ -256,9 +755,9 @@ func TestIsCacheEmptyAtDate(t *testing.T) {
 	t.Run("It should return true if data does not exist at a given date", func(t *testing.T) {
 		// Given
 		mockCache := NewCache(5*time.Microsecond, 5*time.Microsecond, slog.Default())
-		mockCache.data["2006-01-02"] = cacheData{}
+		mockCache.store.Set("2006-01-02", Entry{})
 		// When
-		res := mockCache.IsCacheEmptyAtDate("2006-01-02")
+		res := mockCache.IsCacheEmpty(models.DateFilter("2006-01-02"))
 		// Then
 		assert.Equal(t, true, res)
 	})
@@ -267,7 +766,7 @@ func TestIsCacheEmptyAtDate(t *testing.T) {
 		// Given
 		mockCache := NewCache(5*time.Microsecond, 5*time.Microsecond, slog.Default())
 		// When
-		res := mockCache.IsCacheEmptyAtDate("2006-01-02")
+		res := mockCache.IsCacheEmpty(models.DateFilter("2006-01-02"))
 		// Then
 		assert.Equal(t, true, res)
 	})
@@ -296,8 +795,8 @@ func TestShouldClearCacheData(t *testing.T) {
 func TestClearCacheData(t *testing.T) {
 	// Given
 	mockCache := NewCache(5*time.Microsecond, 5*time.Microsecond, slog.Default())
-	mockCache.data["2006-01-02"] = cacheData{
-		tournamentsAndParticipants: []models.TournamentParticipants{
+	mockCache.store.Set("2006-01-02", Entry{
+		TournamentsAndParticipants: []models.TournamentParticipants{
 			{
 				GameName:     "test",
 				TournamentID: "1234",
@@ -307,11 +806,244 @@ func TestClearCacheData(t *testing.T) {
 				},
 			},
 		},
-	}
+	})
 	// When
 	mockCache.ClearCache()
 	// Then
-	assert.Empty(t, mockCache.data)
+	assert.True(t, mockCache.IsCacheEmpty(models.DateFilter("2006-01-02")))
+}
+
+func TestInvalidate(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mockFetchData := &stubFetchData{
+		tournaments:  map[string]string{"1": "test", "2": "test2"},
+		participants: map[string]map[string]string{"1": {"1": "testName1"}, "2": {"2": "testName2"}},
+	}
+
+	t.Run("It should describe every cached date", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2023-11-25"), mockFetchData))
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		// When
+		entries, err := mockCache.Entries()
+		// Then
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "2006-01-02", entries[0].Date)
+		assert.Equal(t, "2023-11-25", entries[1].Date)
+		assert.Equal(t, 2, entries[0].Tournaments)
+	})
+
+	t.Run("It should only drop the invalidated date", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2023-11-25"), mockFetchData))
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		// When
+		err := mockCache.InvalidateDate("2006-01-02")
+		// Then
+		require.NoError(t, err)
+		assert.True(t, mockCache.IsCacheEmpty(models.DateFilter("2006-01-02")))
+		assert.False(t, mockCache.IsCacheEmpty(models.DateFilter("2023-11-25")))
+	})
+
+	t.Run("It should drop the invalidated tournament and expire its dates", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		// When
+		found, err := mockCache.InvalidateTournament("1")
+		// Then
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, []models.TournamentParticipants{
+			{GameName: "test2", TournamentID: "2", Participant: map[string]string{"2": "testName2"}},
+		}, mockCache.GetData(models.DateFilter("2006-01-02"), nil))
+		assert.True(t, mockCache.ShouldUpdate(models.DateFilter("2006-01-02")))
+	})
+
+	t.Run("It should report a tournament that is not cached", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		// When
+		found, err := mockCache.InvalidateTournament("3")
+		// Then
+		require.NoError(t, err)
+		assert.False(t, found)
+		assert.False(t, mockCache.ShouldUpdate(models.DateFilter("2006-01-02")))
+	})
+}
+
+// stubFetchData serves participants from memory, failing the tournaments listed in failing
+type stubFetchData struct {
+	tournaments    map[string]string
+	tournamentsErr error
+	participants   map[string]map[string]string
+	failing        map[string]error
+}
+
+func (s *stubFetchData) FetchTournaments(ctx context.Context, filter models.TournamentFilter) (map[string]models.TournamentSummary, error) {
+	if s.tournamentsErr != nil {
+		return nil, s.tournamentsErr
+	}
+	return summaries(s.tournaments), nil
+}
+
+func (s *stubFetchData) FetchTournament(ctx context.Context, tournamentId string) (models.TournamentSummary, error) {
+	tournamentGame, ok := s.tournaments[tournamentId]
+	if !ok {
+		return models.TournamentSummary{}, &challongebracketmatches.APIError{StatusCode: http.StatusNotFound, TournamentID: tournamentId}
+	}
+	return models.TournamentSummary{Id: tournamentId, GameName: tournamentGame}, nil
+}
+
+// summaries describes tournaments given as tournamentId -> game name
+func summaries(tournaments map[string]string) map[string]models.TournamentSummary {
+	ret := map[string]models.TournamentSummary{}
+	for tournamentId, tournamentGame := range tournaments {
+		ret[tournamentId] = models.TournamentSummary{Id: tournamentId, GameName: tournamentGame}
+	}
+	return ret
+}
+
+func (s *stubFetchData) FetchParticipants(ctx context.Context, tournamentId, tournamentGame string) (models.TournamentParticipants, error) {
+	if err, ok := s.failing[tournamentId]; ok {
+		return models.TournamentParticipants{}, err
+	}
+	return models.TournamentParticipants{
+		GameName:     tournamentGame,
+		TournamentID: tournamentId,
+		Participant:  s.participants[tournamentId],
+	}, nil
+}
+
+func (s *stubFetchData) FetchMatches(ctx context.Context, tournamentParticipants models.TournamentParticipants, states []models.MatchState) (models.TournamentMatches, error) {
+	return models.TournamentMatches{}, nil
+}
+
+// filterFetchData serves different tournaments for each filter, keyed by the filter's key
+type filterFetchData struct {
+	stubFetchData
+	tournaments map[string]map[string]string
+}
+
+func (f *filterFetchData) FetchTournaments(ctx context.Context, filter models.TournamentFilter) (map[string]models.TournamentSummary, error) {
+	return summaries(f.tournaments[filter.Key()]), nil
+}
+
+// countingFetchData counts tournament fetches and holds each one until release is closed
+type countingFetchData struct {
+	stubFetchData
+	release         chan struct{}
+	tournamentCalls atomic.Int32
+}
+
+func (c *countingFetchData) FetchTournaments(ctx context.Context, filter models.TournamentFilter) (map[string]models.TournamentSummary, error) {
+	c.tournamentCalls.Add(1)
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return c.stubFetchData.FetchTournaments(ctx, filter)
+}
+
+// rosterFetchData counts participant fetches per tournament and reports unknownParticipants and resolvedParticipants
+// in every match list
+type rosterFetchData struct {
+	stubFetchData
+	unknownParticipants  []string
+	resolvedParticipants map[string]string
+	mu                   sync.Mutex
+	participantCalls     map[string]int
+}
+
+func (r *rosterFetchData) FetchParticipants(ctx context.Context, tournamentId, tournamentGame string) (models.TournamentParticipants, error) {
+	r.mu.Lock()
+	r.participantCalls[tournamentId]++
+	r.mu.Unlock()
+	return r.stubFetchData.FetchParticipants(ctx, tournamentId, tournamentGame)
+}
+
+func (r *rosterFetchData) FetchMatches(ctx context.Context, tournamentParticipants models.TournamentParticipants, states []models.MatchState) (models.TournamentMatches, error) {
+	return models.TournamentMatches{
+		GameName:              tournamentParticipants.GameName,
+		TournamentId:          tournamentParticipants.TournamentID,
+		MatchList:             []models.Match{},
+		UnknownParticipantIds: r.unknownParticipants,
+		ResolvedParticipants:  r.resolvedParticipants,
+	}, nil
+}
+
+func (r *rosterFetchData) calls() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.participantCalls)
+}
+
+// blockingFetchData returns tournaments immediately and blocks every participant fetch until its context is done
+type blockingFetchData struct {
+	tournaments map[string]string
+	started     chan struct{}
+	inFlight    atomic.Int32
+}
+
+func (b *blockingFetchData) FetchTournaments(ctx context.Context, filter models.TournamentFilter) (map[string]models.TournamentSummary, error) {
+	return summaries(b.tournaments), nil
+}
+
+func (b *blockingFetchData) FetchTournament(ctx context.Context, tournamentId string) (models.TournamentSummary, error) {
+	return models.TournamentSummary{Id: tournamentId, GameName: b.tournaments[tournamentId]}, nil
+}
+
+func (b *blockingFetchData) FetchParticipants(ctx context.Context, tournamentId, tournamentGame string) (models.TournamentParticipants, error) {
+	b.inFlight.Add(1)
+	defer b.inFlight.Add(-1)
+	b.started <- struct{}{}
+	<-ctx.Done()
+	return models.TournamentParticipants{}, ctx.Err()
+}
+
+func (b *blockingFetchData) FetchMatches(ctx context.Context, tournamentParticipants models.TournamentParticipants, states []models.MatchState) (models.TournamentMatches, error) {
+	return models.TournamentMatches{}, nil
+}
+
+// slowFetchData takes delay to answer every fetch and records the deadline its tournaments were fetched with
+type slowFetchData struct {
+	stubFetchData
+	delay    time.Duration
+	deadline time.Time
+}
+
+func (s *slowFetchData) FetchTournaments(ctx context.Context, filter models.TournamentFilter) (map[string]models.TournamentSummary, error) {
+	s.deadline, _ = ctx.Deadline()
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.stubFetchData.FetchTournaments(ctx, filter)
+}
+
+func (s *slowFetchData) FetchParticipants(ctx context.Context, tournamentId, tournamentGame string) (models.TournamentParticipants, error) {
+	if err := s.wait(ctx); err != nil {
+		return models.TournamentParticipants{}, err
+	}
+	return s.stubFetchData.FetchParticipants(ctx, tournamentId, tournamentGame)
+}
+
+// wait takes delay unless ctx is done first. A fetch still running past its deadline fails even when the context's
+// timer has yet to fire, so the outcome doesn't depend on scheduling
+func (s *slowFetchData) wait(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
 }
 
 // helper functions
@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/models"
+)
+
+// DefaultMatchTTL is how long a tournament's match list is served before it is fetched again
+const DefaultMatchTTL = 10 * time.Second
+
+type (
+	matchEntry struct {
+		matches   models.TournamentMatches
+		timeStamp time.Time
+	}
+
+	// matchCache holds each tournament's match list for a short time so every client polling shares one fetch
+	matchCache struct {
+		mu        sync.RWMutex
+		ttl       time.Duration
+		entries   map[string]matchEntry
+		refreshes refreshGroup
+		hits      atomic.Int64
+		misses    atomic.Int64
+		// participantMisses counts participants matches referenced that were missing from the cached rosters
+		participantMisses atomic.Int64
+	}
+
+	// MatchCacheStats counts the match lookups served from the cache and the ones that went to Challonge,
+	// alongside the participants that had to be looked up because they were missing from the cached rosters
+	MatchCacheStats struct {
+		Hits              int64 `json:"hits"`
+		Misses            int64 `json:"misses"`
+		ParticipantMisses int64 `json:"participant_misses"`
+	}
+)
+
+// WithMatchTTL overrides DefaultMatchTTL. A ttl of zero still collapses concurrent fetches but keeps nothing
+func WithMatchTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.matches.ttl = ttl
+	}
+}
+
+// GetMatches returns the matches of a tournament in states, fetching them only if the cached list is older than the
+// match ttl. Concurrent misses for the same tournament and states share a single fetch
+func (c *Cache) GetMatches(ctx context.Context, tournamentParticipants models.TournamentParticipants, states []models.MatchState, fetchData challongebracketmatches.FetchData) (models.TournamentMatches, error) {
+	tournamentId := tournamentParticipants.TournamentID
+	key := matchKey(tournamentId, states)
+	if entry, ok := c.matches.get(key); ok && time.Since(entry.timeStamp) < c.matches.ttl {
+		c.matches.hits.Add(1)
+		return cloneMatches(entry.matches), nil
+	}
+	c.matches.misses.Add(1)
+
+	fetched, err := c.matches.refreshes.load(ctx, key, func(ctx context.Context) (any, error) {
+		matches, err := fetchData.FetchMatches(ctx, tournamentParticipants, states)
+		if err != nil {
+			return nil, err
+		}
+		c.matches.participantMisses.Add(int64(len(matches.ResolvedParticipants) + len(matches.UnknownParticipantIds)))
+		if len(matches.ResolvedParticipants) > 0 {
+			c.addParticipants(tournamentId, matches.ResolvedParticipants)
+		}
+		if len(matches.UnknownParticipantIds) > 0 {
+			c.logger.Warn("Matches reference unknown participants, expiring roster", "tournament", tournamentId, "participants", matches.UnknownParticipantIds)
+			c.expireRoster(tournamentId)
+		}
+		c.matches.set(key, matches)
+		return matches, nil
+	})
+	if err != nil {
+		return models.TournamentMatches{}, err
+	}
+
+	// the fetched list is returned even if the cache was cleared since
+	return cloneMatches(fetched.(models.TournamentMatches)), nil
+}
+
+// GetMatchesConcurrently gets the matches in states of every tournament through the match cache, returning the ones
+// that succeeded ordered by game alongside an error for each one that did not
+func (c *Cache) GetMatchesConcurrently(ctx context.Context, tournaments []models.TournamentParticipants, states []models.MatchState, fetchData challongebracketmatches.FetchData) ([]models.TournamentMatches, []challongebracketmatches.TournamentError) {
+	matches := []models.TournamentMatches{}
+	var fetchErrors []challongebracketmatches.TournamentError
+
+	// buffered so no goroutine is left blocked on send
+	chanResponse := make(chan struct {
+		tournamentMatches models.TournamentMatches
+		err               *challongebracketmatches.TournamentError
+	}, len(tournaments))
+	var wg sync.WaitGroup
+	for _, elem := range tournaments {
+		wg.Add(1)
+		go func(tournament models.TournamentParticipants) {
+			defer wg.Done()
+			match, err := c.GetMatches(ctx, tournament, states, fetchData)
+			result := struct {
+				tournamentMatches models.TournamentMatches
+				err               *challongebracketmatches.TournamentError
+			}{
+				tournamentMatches: match,
+			}
+			if err != nil {
+				result.err = &challongebracketmatches.TournamentError{
+					TournamentID: tournament.TournamentID,
+					GameName:     tournament.GameName,
+					Err:          err,
+				}
+			}
+			chanResponse <- result
+		}(elem)
+	}
+
+	wg.Wait()
+	close(chanResponse)
+
+	for getMatchesResult := range chanResponse {
+		if getMatchesResult.err != nil {
+			fetchErrors = append(fetchErrors, *getMatchesResult.err)
+			continue
+		}
+		matches = append(matches, getMatchesResult.tournamentMatches)
+	}
+
+	slices.SortFunc(matches, func(a, b models.TournamentMatches) int {
+		return cmp.Or(strings.Compare(a.GameName, b.GameName), strings.Compare(a.TournamentId, b.TournamentId))
+	})
+	slices.SortFunc(fetchErrors, func(a, b challongebracketmatches.TournamentError) int {
+		return strings.Compare(a.TournamentID, b.TournamentID)
+	})
+
+	return matches, fetchErrors
+}
+
+// MatchStats returns the match cache's hit and miss counts since the server started
+func (c *Cache) MatchStats() MatchCacheStats {
+	return MatchCacheStats{
+		Hits:              c.matches.hits.Load(),
+		Misses:            c.matches.misses.Load(),
+		ParticipantMisses: c.matches.participantMisses.Load(),
+	}
+}
+
+// matchKey identifies the match list of a tournament filtered on states, which are expected sorted
+func matchKey(tournamentId string, states []models.MatchState) string {
+	key := tournamentId
+	for _, state := range states {
+		key += "|" + string(state)
+	}
+	return key
+}
+
+func (m *matchCache) get(key string) (matchEntry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[key]
+	return entry, ok
+}
+
+func (m *matchCache) set(key string, matches models.TournamentMatches) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.entries == nil {
+		m.entries = map[string]matchEntry{}
+	}
+	m.entries[key] = matchEntry{
+		matches:   matches,
+		timeStamp: time.Now(),
+	}
+}
+
+// delete drops the match lists of tournamentId for every set of states
+func (m *matchCache) delete(tournamentId string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := false
+	for key, entry := range m.entries {
+		if entry.matches.TournamentId == tournamentId {
+			delete(m.entries, key)
+			found = true
+		}
+	}
+	return found
+}
+
+func (m *matchCache) clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries = map[string]matchEntry{}
+}
+
+// cloneMatches copies the match list so callers can't modify the cached one
+func cloneMatches(matches models.TournamentMatches) models.TournamentMatches {
+	matches.MatchList = slices.Clone(matches.MatchList)
+	return matches
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMatches(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	givenTournament := models.TournamentParticipants{GameName: "test", TournamentID: "1", Participant: map[string]string{"1": "testName1"}}
+
+	t.Run("It should serve matches from the cache until the ttl runs out", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger, WithMatchTTL(time.Hour))
+		mockFetchData := &matchFetchData{}
+		_, err := mockCache.GetMatches(context.Background(), givenTournament, models.DefaultMatchStates, mockFetchData)
+		require.NoError(t, err)
+		// When
+		gotMatches, err := mockCache.GetMatches(context.Background(), givenTournament, models.DefaultMatchStates, mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, "1", gotMatches.TournamentId)
+		assert.Len(t, gotMatches.MatchList, 1)
+		assert.Equal(t, int32(1), mockFetchData.matchCalls.Load())
+		assert.Equal(t, MatchCacheStats{Hits: 1, Misses: 1}, mockCache.MatchStats())
+	})
+
+	t.Run("It should fetch matches again once the ttl has run out", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger, WithMatchTTL(time.Nanosecond))
+		mockFetchData := &matchFetchData{}
+		_, err := mockCache.GetMatches(context.Background(), givenTournament, models.DefaultMatchStates, mockFetchData)
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond)
+		// When
+		_, err = mockCache.GetMatches(context.Background(), givenTournament, models.DefaultMatchStates, mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), mockFetchData.matchCalls.Load())
+		assert.Equal(t, MatchCacheStats{Hits: 0, Misses: 2}, mockCache.MatchStats())
+	})
+
+	t.Run("It should not cache a failed fetch", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger, WithMatchTTL(time.Hour))
+		mockFetchData := &matchFetchData{err: errors.New("fetch failed")}
+		_, err := mockCache.GetMatches(context.Background(), givenTournament, models.DefaultMatchStates, mockFetchData)
+		require.Error(t, err)
+		// When
+		_, err = mockCache.GetMatches(context.Background(), givenTournament, models.DefaultMatchStates, mockFetchData)
+		// Then
+		assert.Error(t, err)
+		assert.Equal(t, int32(2), mockFetchData.matchCalls.Load())
+	})
+
+	t.Run("It should share one fetch between concurrent misses", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger, WithMatchTTL(time.Hour))
+		mockFetchData := &matchFetchData{release: make(chan struct{})}
+		// When
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				gotMatches, err := mockCache.GetMatches(context.Background(), givenTournament, models.DefaultMatchStates, mockFetchData)
+				assert.NoError(t, err)
+				assert.Equal(t, "1", gotMatches.TournamentId)
+				assert.Len(t, gotMatches.MatchList, 1)
+			}()
+		}
+		assert.Eventually(t, func() bool {
+			return mockCache.matches.refreshes.inFlight(matchKey("1", models.DefaultMatchStates)) == 10
+		}, time.Second, time.Millisecond)
+		close(mockFetchData.release)
+		wg.Wait()
+		// Then
+		assert.Equal(t, int32(1), mockFetchData.matchCalls.Load())
+	})
+
+	t.Run("It should cache each set of states separately", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger, WithMatchTTL(time.Hour))
+		mockFetchData := &matchFetchData{}
+		_, err := mockCache.GetMatches(context.Background(), givenTournament, models.DefaultMatchStates, mockFetchData)
+		require.NoError(t, err)
+		// When
+		_, err = mockCache.GetMatches(context.Background(), givenTournament, []models.MatchState{models.MatchStateComplete}, mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), mockFetchData.matchCalls.Load())
+		found, err := mockCache.InvalidateTournament("1")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Empty(t, mockCache.matches.entries)
+	})
+
+	t.Run("It should drop cached matches when the cache is cleared", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger, WithMatchTTL(time.Hour))
+		mockFetchData := &matchFetchData{}
+		_, err := mockCache.GetMatches(context.Background(), givenTournament, models.DefaultMatchStates, mockFetchData)
+		require.NoError(t, err)
+		// When
+		mockCache.ClearCache()
+		_, err = mockCache.GetMatches(context.Background(), givenTournament, models.DefaultMatchStates, mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), mockFetchData.matchCalls.Load())
+	})
+}
+
+func TestGetMatchesConcurrently(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	givenTournaments := []models.TournamentParticipants{
+		{GameName: "test2", TournamentID: "2"},
+		{GameName: "test1", TournamentID: "3"},
+		{GameName: "test1", TournamentID: "1"},
+	}
+
+	t.Run("It should return the matches of every tournament ordered by game", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		// When
+		gotMatches, gotErrors := mockCache.GetMatchesConcurrently(context.Background(), givenTournaments, models.DefaultMatchStates, &matchFetchData{})
+		// Then
+		assert.Empty(t, gotErrors)
+		gotIds := []string{}
+		for _, tournament := range gotMatches {
+			gotIds = append(gotIds, tournament.TournamentId)
+		}
+		assert.Equal(t, []string{"1", "3", "2"}, gotIds)
+	})
+
+	t.Run("It should return an error for each tournament that failed, ordered by tournament", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		// When
+		gotMatches, gotErrors := mockCache.GetMatchesConcurrently(context.Background(), givenTournaments, models.DefaultMatchStates, &matchFetchData{err: errors.New("fetch failed")})
+		// Then
+		assert.Empty(t, gotMatches)
+		require.Len(t, gotErrors, 3)
+		assert.Equal(t, "1", gotErrors[0].TournamentID)
+		assert.Equal(t, "test1", gotErrors[0].GameName)
+		assert.Equal(t, "2", gotErrors[1].TournamentID)
+		assert.Equal(t, "3", gotErrors[2].TournamentID)
+	})
+}
+
+// matchFetchData counts match fetches, holding each one until release is closed when it is set
+type matchFetchData struct {
+	stubFetchData
+	err        error
+	release    chan struct{}
+	matchCalls atomic.Int32
+}
+
+func (m *matchFetchData) FetchMatches(ctx context.Context, tournamentParticipants models.TournamentParticipants, states []models.MatchState) (models.TournamentMatches, error) {
+	m.matchCalls.Add(1)
+	if m.release != nil {
+		<-m.release
+	}
+	if m.err != nil {
+		return models.TournamentMatches{}, m.err
+	}
+	return models.TournamentMatches{
+		GameName:     tournamentParticipants.GameName,
+		TournamentId: tournamentParticipants.TournamentID,
+		MatchList:    []models.Match{{Id: "10", Player1Name: tournamentParticipants.Participant["1"]}},
+	}, nil
+}
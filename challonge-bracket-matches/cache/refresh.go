@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// refresh is a cache update in flight that every concurrent caller for the same date waits on
+	refresh struct {
+		done    chan struct{}
+		value   any
+		err     error
+		waiters int
+		cancel  context.CancelFunc
+	}
+
+	// refreshGroup collapses concurrent refreshes of the same key into one. Unlike singleflight the shared
+	// refresh is only cancelled once every caller waiting on it has given up
+	refreshGroup struct {
+		mu        sync.Mutex
+		refreshes map[string]*refresh
+		// timeout bounds each shared refresh as a whole. Zero leaves it unbounded
+		timeout time.Duration
+	}
+)
+
+// do runs fn once for all concurrent callers with the same key and returns its error
+func (g *refreshGroup) do(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	_, err := g.load(ctx, key, func(ctx context.Context) (any, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
+
+// load runs fn once for all concurrent callers with the same key and hands each of them what it returned
+func (g *refreshGroup) load(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+	g.mu.Lock()
+	if g.refreshes == nil {
+		g.refreshes = map[string]*refresh{}
+	}
+	r, ok := g.refreshes[key]
+	if !ok {
+		refreshCtx, cancel := g.refreshContext(ctx)
+		r = &refresh{
+			done:   make(chan struct{}),
+			cancel: cancel,
+		}
+		g.refreshes[key] = r
+		go func() {
+			defer cancel()
+			r.value, r.err = fn(refreshCtx)
+			g.forget(key, r)
+			close(r.done)
+		}()
+	}
+	r.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		r.waiters--
+		if r.waiters == 0 {
+			r.cancel()
+			// callers arriving from now on start a new refresh instead of joining a cancelled one
+			g.forgetLocked(key, r)
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// refreshContext detaches the refresh from the cancellation of the caller starting it, since other callers may join it,
+// while keeping the caller's deadline when it comes before the group's timeout
+func (g *refreshGroup) refreshContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if g.timeout > 0 && (!ok || time.Now().Add(g.timeout).Before(deadline)) {
+		deadline, ok = time.Now().Add(g.timeout), true
+	}
+	if !ok {
+		return context.WithCancel(context.WithoutCancel(ctx))
+	}
+	return context.WithDeadline(context.WithoutCancel(ctx), deadline)
+}
+
+// inFlight returns how many callers are waiting on the refresh for key
+func (g *refreshGroup) inFlight(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if r, ok := g.refreshes[key]; ok {
+		return r.waiters
+	}
+	return 0
+}
+
+func (g *refreshGroup) forget(key string, r *refresh) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.forgetLocked(key, r)
+}
+
+func (g *refreshGroup) forgetLocked(key string, r *refresh) {
+	if g.refreshes[key] == r {
+		delete(g.refreshes, key)
+	}
+}
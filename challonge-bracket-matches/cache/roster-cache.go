@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/models"
+)
+
+type (
+	rosterEntry struct {
+		participants models.TournamentParticipants
+		timeStamp    time.Time
+	}
+
+	// rosterCache holds the participants of tournaments looked up on their own, outside of any filter's entry
+	rosterCache struct {
+		mu        sync.RWMutex
+		entries   map[string]rosterEntry
+		refreshes refreshGroup
+	}
+)
+
+// GetParticipants returns the participants of a single tournament, reusing the freshest roster cached for it while it
+// is younger than the roster refresh. A tournament the cache knows nothing about is fetched first to find its game.
+// Concurrent misses for the same tournament share a single fetch
+func (c *Cache) GetParticipants(ctx context.Context, tournamentId string, fetchData challongebracketmatches.FetchData) (models.TournamentParticipants, error) {
+	cached, ok := c.latestRoster(tournamentId)
+	if ok && time.Since(cached.timeStamp) < c.rosterRefresh {
+		return cloneParticipants(cached.participants), nil
+	}
+
+	fetched, err := c.rosters.refreshes.load(ctx, tournamentId, func(ctx context.Context) (any, error) {
+		tournamentGame := cached.participants.GameName
+		if !ok {
+			tournament, err := fetchData.FetchTournament(ctx, tournamentId)
+			if err != nil {
+				return nil, err
+			}
+			tournamentGame = tournament.GameName
+		}
+		participants, err := fetchData.FetchParticipants(ctx, tournamentId, tournamentGame)
+		if err != nil {
+			return nil, err
+		}
+		c.rosters.set(tournamentId, participants)
+		return participants, nil
+	})
+	if err != nil {
+		return models.TournamentParticipants{}, err
+	}
+
+	// the fetched roster is returned even if the cache was cleared since
+	return cloneParticipants(fetched.(models.TournamentParticipants)), nil
+}
+
+// latestRoster finds the most recently fetched roster of tournamentId across every filter's entry and the single
+// lookups. A tournament only known from its summary comes back with its game name, an empty roster and no time stamp
+func (c *Cache) latestRoster(tournamentId string) (rosterEntry, bool) {
+	latest, found := c.rosters.get(tournamentId)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys, err := c.store.Keys()
+	if err != nil {
+		c.logger.Error("Error reading from cache store", "error", err)
+		return latest, found
+	}
+	for _, key := range keys {
+		data, ok := c.entry(key)
+		if !ok {
+			continue
+		}
+		if tournament, ok := findTournament(data, tournamentId); ok {
+			fetchedAt := data.RosterTimeStamps[tournamentId]
+			if !found || fetchedAt.After(latest.timeStamp) {
+				latest = rosterEntry{participants: tournament, timeStamp: fetchedAt}
+				found = true
+			}
+			continue
+		}
+		if summary, ok := data.Tournaments[tournamentId]; ok && !found {
+			latest = rosterEntry{participants: models.TournamentParticipants{GameName: summary.GameName, TournamentID: tournamentId}}
+			found = true
+		}
+	}
+	return latest, found
+}
+
+// CachedTournaments returns every tournament the cache holds a roster for, with its freshest roster, ordered by id
+func (c *Cache) CachedTournaments() []models.TournamentParticipants {
+	latest := map[string]rosterEntry{}
+	c.rosters.mu.RLock()
+	maps.Copy(latest, c.rosters.entries)
+	c.rosters.mu.RUnlock()
+
+	c.mu.RLock()
+	keys, err := c.store.Keys()
+	if err != nil {
+		c.logger.Error("Error reading from cache store", "error", err)
+	}
+	for _, key := range keys {
+		data, ok := c.entry(key)
+		if !ok {
+			continue
+		}
+		for _, tournament := range data.TournamentsAndParticipants {
+			fetchedAt := data.RosterTimeStamps[tournament.TournamentID]
+			if previous, ok := latest[tournament.TournamentID]; !ok || fetchedAt.After(previous.timeStamp) {
+				latest[tournament.TournamentID] = rosterEntry{participants: tournament, timeStamp: fetchedAt}
+			}
+		}
+	}
+	c.mu.RUnlock()
+
+	tournaments := make([]models.TournamentParticipants, 0, len(latest))
+	for _, entry := range latest {
+		tournaments = append(tournaments, cloneParticipants(entry.participants))
+	}
+	slices.SortFunc(tournaments, func(a, b models.TournamentParticipants) int {
+		return strings.Compare(a.TournamentID, b.TournamentID)
+	})
+	return tournaments
+}
+
+func (r *rosterCache) get(tournamentId string) (rosterEntry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.entries[tournamentId]
+	return entry, ok
+}
+
+func (r *rosterCache) set(tournamentId string, participants models.TournamentParticipants) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.entries == nil {
+		r.entries = map[string]rosterEntry{}
+	}
+	r.entries[tournamentId] = rosterEntry{
+		participants: participants,
+		timeStamp:    time.Now(),
+	}
+}
+
+// add merges participants into the roster of tournamentId, if it is held
+func (r *rosterCache) add(tournamentId string, participants map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.entries[tournamentId]
+	if !ok {
+		return
+	}
+	roster := maps.Clone(entry.participants.Participant)
+	if roster == nil {
+		roster = map[string]string{}
+	}
+	maps.Copy(roster, participants)
+	entry.participants.Participant = roster
+	r.entries[tournamentId] = entry
+}
+
+func (r *rosterCache) delete(tournamentId string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.entries[tournamentId]
+	delete(r.entries, tournamentId)
+	return ok
+}
+
+func (r *rosterCache) clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = map[string]rosterEntry{}
+}
+
+// cloneParticipants copies the roster so callers can't modify the cached one
+func cloneParticipants(participants models.TournamentParticipants) models.TournamentParticipants {
+	participants.Participant = maps.Clone(participants.Participant)
+	participants.Usernames = maps.Clone(participants.Usernames)
+	return participants
+}
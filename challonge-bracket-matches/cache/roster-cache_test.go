@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetParticipants(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	newFetchData := func() *rosterFetchData {
+		return &rosterFetchData{
+			stubFetchData: stubFetchData{
+				tournaments:  map[string]string{"1": "test", "2": "test2"},
+				participants: map[string]map[string]string{"1": {"1": "testName1"}, "2": {"2": "testName2"}},
+				failing:      map[string]error{"2": errors.New("participants failed")},
+			},
+			participantCalls: map[string]int{},
+		}
+	}
+
+	t.Run("It should reuse the roster cached for a filter", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := newFetchData()
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		// When
+		gotParticipants, err := mockCache.GetParticipants(context.Background(), "1", mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, models.TournamentParticipants{
+			GameName:     "test",
+			TournamentID: "1",
+			Participant:  map[string]string{"1": "testName1"},
+		}, gotParticipants)
+		assert.Equal(t, map[string]int{"1": 1, "2": 1}, mockFetchData.calls())
+	})
+
+	t.Run("It should fetch a tournament that is not cached and keep it until the roster refresh", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := newFetchData()
+		_, err := mockCache.GetParticipants(context.Background(), "1", mockFetchData)
+		require.NoError(t, err)
+		// When
+		gotParticipants, err := mockCache.GetParticipants(context.Background(), "1", mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"1": "testName1"}, gotParticipants.Participant)
+		assert.Equal(t, map[string]int{"1": 1}, mockFetchData.calls())
+	})
+
+	t.Run("It should look up the game of a tournament that is not cached", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := newFetchData()
+		// When
+		gotParticipants, err := mockCache.GetParticipants(context.Background(), "1", mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, "test", gotParticipants.GameName)
+	})
+
+	t.Run("It should return the error of a tournament missing from Challonge without fetching its participants", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := newFetchData()
+		// When
+		_, err := mockCache.GetParticipants(context.Background(), "3", mockFetchData)
+		// Then
+		var apiErr *challongebracketmatches.APIError
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Empty(t, mockFetchData.calls())
+	})
+
+	t.Run("It should fetch again once the roster refresh has passed, keeping the game name of the filter's summary", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger, WithRosterRefresh(time.Nanosecond))
+		mockFetchData := newFetchData()
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		time.Sleep(time.Millisecond)
+		// When
+		gotParticipants, err := mockCache.GetParticipants(context.Background(), "1", mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, "test", gotParticipants.GameName)
+		assert.Equal(t, 2, mockFetchData.calls()["1"])
+	})
+
+	t.Run("It should return the error of a failed fetch", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := newFetchData()
+		// When
+		_, err := mockCache.GetParticipants(context.Background(), "2", mockFetchData)
+		// Then
+		assert.EqualError(t, err, "participants failed")
+	})
+
+	t.Run("It should drop a looked up roster when the tournament is invalidated", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := newFetchData()
+		_, err := mockCache.GetParticipants(context.Background(), "1", mockFetchData)
+		require.NoError(t, err)
+		// When
+		found, err := mockCache.InvalidateTournament("1")
+		require.NoError(t, err)
+		_, err = mockCache.GetParticipants(context.Background(), "1", mockFetchData)
+		// Then
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, map[string]int{"1": 2}, mockFetchData.calls())
+	})
+
+	t.Run("It should not let callers modify the cached roster", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(time.Minute, time.Hour, logger)
+		mockFetchData := newFetchData()
+		gotParticipants, err := mockCache.GetParticipants(context.Background(), "1", mockFetchData)
+		require.NoError(t, err)
+		// When
+		gotParticipants.Participant["1"] = "changed"
+		// Then
+		gotParticipants, err = mockCache.GetParticipants(context.Background(), "1", mockFetchData)
+		require.NoError(t, err)
+		assert.Equal(t, "testName1", gotParticipants.Participant["1"])
+	})
+}
+
+func TestCachedTournaments(t *testing.T) {
+	// Given
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mockCache := NewCache(time.Minute, time.Hour, logger)
+	givenEnded := models.TournamentFilter{CreatedAfter: "2006-01-01", State: models.TournamentStateEnded}
+	mockFetchData := &filterFetchData{
+		stubFetchData: stubFetchData{
+			tournaments:  map[string]string{"1": "test", "2": "test2", "3": "test3"},
+			participants: map[string]map[string]string{"1": {"1": "testName1"}, "2": {"2": "testName2"}, "3": {"3": "testName3"}},
+		},
+		tournaments: map[string]map[string]string{
+			models.DateFilter("2006-01-02").Key(): {"2": "test2", "1": "test"},
+			givenEnded.Key():                      {"2": "test2", "1": "test"},
+		},
+	}
+	require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+	require.NoError(t, mockCache.UpdateCache(context.Background(), givenEnded, mockFetchData))
+	_, err := mockCache.GetParticipants(context.Background(), "3", mockFetchData)
+	require.NoError(t, err)
+	// When
+	gotTournaments := mockCache.CachedTournaments()
+	// Then
+	assert.Equal(t, []models.TournamentParticipants{
+		{GameName: "test", TournamentID: "1", Participant: map[string]string{"1": "testName1"}},
+		{GameName: "test2", TournamentID: "2", Participant: map[string]string{"2": "testName2"}},
+		{GameName: "test3", TournamentID: "3", Participant: map[string]string{"3": "testName3"}},
+	}, gotTournaments)
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"time"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/models"
+)
+
+type (
+	// Entry is what the cache holds for a single tournament filter
+	Entry struct {
+		// Tournaments describes every tournament matching the filter, including those whose participants could not be fetched
+		Tournaments                map[string]models.TournamentSummary `json:"tournaments"`
+		TournamentsAndParticipants []models.TournamentParticipants     `json:"tournaments_and_participants"`
+		// RosterTimeStamps is when the participants of each tournament were last fetched
+		RosterTimeStamps map[string]time.Time `json:"roster_time_stamps"`
+		// FetchErrors are not persisted, they only describe the last update made by this process
+		FetchErrors []challongebracketmatches.TournamentError `json:"-"`
+		TimeStamp   time.Time                                 `json:"time_stamp"`
+		// Filter is what the entry was fetched for, entries written before filters were stored leave it empty
+		Filter models.TournamentFilter `json:"filter"`
+	}
+
+	// Store is where a Cache keeps its entries, keyed by TournamentFilter.Key. The Cache serialises calls to it with its own lock
+	Store interface {
+		// Get returns the entry for key, false if there is none
+		Get(key string) (Entry, bool, error)
+		Set(key string, entry Entry) error
+		// Delete removes the entry for key, if there is one
+		Delete(key string) error
+		// Keys returns the key of every entry
+		Keys() ([]string, error)
+		// Clear removes every entry
+		Clear() error
+		Close() error
+	}
+
+	// MemoryStore keeps entries in a map and loses them on restart
+	MemoryStore struct {
+		data map[string]Entry
+	}
+)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data: map[string]Entry{},
+	}
+}
+
+func (m *MemoryStore) Get(key string) (Entry, bool, error) {
+	entry, ok := m.data[key]
+	return entry, ok, nil
+}
+
+func (m *MemoryStore) Set(key string, entry Entry) error {
+	m.data[key] = entry
+	return nil
+}
+
+func (m *MemoryStore) Delete(key string) error {
+	delete(m.data, key)
+	return nil
+}
+
+func (m *MemoryStore) Keys() ([]string, error) {
+	keys := make([]string, 0, len(m.data))
+	for key := range m.data {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (m *MemoryStore) Clear() error {
+	m.data = map[string]Entry{}
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
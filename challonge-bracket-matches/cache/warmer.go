@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/models"
+)
+
+// today is the placeholder in a warmer's dates for the current date in its location
+const today = "today"
+
+// Warmer refreshes a Cache in the background so requests find warm data. A failed refresh leaves the
+// previous data in the cache untouched
+type Warmer struct {
+	cache     *Cache
+	fetchData challongebracketmatches.FetchData
+	dates     []string
+	location  *time.Location
+	interval  time.Duration
+	jitter    time.Duration
+	logger    *slog.Logger
+}
+
+// NewWarmer creates a Warmer refreshing dates every interval plus up to jitter. Dates are in the
+// 2006-01-02 format, or "today" to follow the current date in location
+func NewWarmer(cache *Cache, fetchData challongebracketmatches.FetchData, dates []string, location *time.Location, interval, jitter time.Duration, logger *slog.Logger) *Warmer {
+	if len(dates) == 0 {
+		dates = []string{today}
+	}
+	return &Warmer{
+		cache:     cache,
+		fetchData: fetchData,
+		dates:     dates,
+		location:  location,
+		interval:  interval,
+		jitter:    jitter,
+		logger:    logger,
+	}
+}
+
+// Run refreshes the cache straight away and then on every tick until ctx is done
+func (w *Warmer) Run(ctx context.Context) {
+	w.logger.Info("Cache warmer started", "dates", strings.Join(w.dates, ","), "interval", w.interval)
+	for {
+		w.warm(ctx)
+
+		timer := time.NewTimer(w.nextDelay())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			w.logger.Info("Cache warmer stopped")
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *Warmer) warm(ctx context.Context) {
+	for _, date := range w.resolveDates(time.Now()) {
+		start := time.Now()
+		if err := w.cache.UpdateCache(ctx, models.DateFilter(date), w.fetchData); err != nil {
+			w.logger.Error("Cache warm failed, serving last known data", "date", date, "duration", time.Since(start), "error", err)
+			continue
+		}
+		w.logger.Info("Cache warmed", "date", date, "duration", time.Since(start))
+	}
+}
+
+func (w *Warmer) nextDelay() time.Duration {
+	if w.jitter <= 0 {
+		return w.interval
+	}
+	return w.interval + time.Duration(rand.Int63n(int64(w.jitter)))
+}
+
+// resolveDates replaces "today" with the date of now in the warmer's location
+func (w *Warmer) resolveDates(now time.Time) []string {
+	dates := make([]string, 0, len(w.dates))
+	for _, date := range w.dates {
+		if date == today {
+			date = now.In(w.location).Format("2006-01-02")
+		}
+		dates = append(dates, date)
+	}
+	return dates
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmerResolveDates(t *testing.T) {
+	// Given
+	location, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	mockWarmer := NewWarmer(nil, nil, []string{"today", "2023-11-25"}, location, time.Minute, 0, slog.Default())
+	// 2am UTC is still the previous day in New York
+	now := time.Date(2023, 11, 26, 2, 0, 0, 0, time.UTC)
+	// When
+	res := mockWarmer.resolveDates(now)
+	// Then
+	assert.Equal(t, []string{"2023-11-25", "2023-11-25"}, res)
+}
+
+func TestWarmerRun(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("It should keep refreshing the cache until stopped", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(5*time.Minute, 5*time.Hour, logger)
+		mockFetchData := &countingFetchData{
+			stubFetchData: stubFetchData{
+				tournaments:  map[string]string{"1": "test"},
+				participants: map[string]map[string]string{"1": {"1": "testName1"}},
+			},
+			release: make(chan struct{}),
+		}
+		close(mockFetchData.release)
+		mockWarmer := NewWarmer(mockCache, mockFetchData, []string{"2006-01-02"}, time.UTC, time.Millisecond, time.Millisecond, logger)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		// When
+		go func() {
+			mockWarmer.Run(ctx)
+			close(done)
+		}()
+		// Then
+		assert.Eventually(t, func() bool {
+			return mockFetchData.tournamentCalls.Load() >= 3
+		}, time.Second, time.Millisecond)
+		assert.False(t, mockCache.IsCacheEmpty(models.DateFilter("2006-01-02")))
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("warmer did not stop after its context was cancelled")
+		}
+	})
+
+	t.Run("It should keep the last known data when a refresh fails", func(t *testing.T) {
+		// Given
+		mockCache := NewCache(5*time.Minute, 5*time.Hour, logger)
+		previous := []models.TournamentParticipants{{GameName: "test", TournamentID: "1", Participant: map[string]string{"1": "testName1"}}}
+		mockCache.store.Set("2006-01-02", Entry{TournamentsAndParticipants: previous})
+		mockFetchData := &stubFetchData{tournamentsErr: challongebracketmatches.ErrServerProblem}
+		mockWarmer := NewWarmer(mockCache, mockFetchData, []string{"2006-01-02"}, time.UTC, time.Hour, 0, logger)
+		// When
+		mockWarmer.warm(context.Background())
+		// Then
+		assert.Equal(t, previous, mockCache.GetData(models.DateFilter("2006-01-02"), nil))
+	})
+}
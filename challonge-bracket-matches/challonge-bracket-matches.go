@@ -1,11 +1,15 @@
 package challongebracketmatches
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
 	"sort"
 	"strconv"
 	"time"
@@ -25,19 +29,27 @@ type (
 		client  *http.Client
 		// apiKeyTravCntlr string
 		// apiKeySNS       string
-		apiKey string
+		apiKey         string
+		contextTimeout time.Duration
+		retryPolicy    RetryPolicy
+		throttle       *throttle
+		// pageSize overrides how many records each list request asks for when set
+		pageSize int
 	}
 
 	FetchData interface {
-		// FetchTournaments fetch all tournaments created after a specific date
-		// GET https://api.challonge.com/v2.1/tournaments.json?page={}&per_page=25
-		FetchTournaments(date string) (map[string]string, error)
+		// FetchTournaments fetch all tournaments matching filter
+		// GET https://api.challonge.com/v2.1/tournaments.json?page={}&per_page=25&state={}&created_after={}&created_before={}
+		FetchTournaments(ctx context.Context, filter models.TournamentFilter) (map[string]models.TournamentSummary, error)
+		// FetchTournament fetch a single tournament
+		// GET https://api.challonge.com/v2.1/tournaments/{tournament}.json
+		FetchTournament(ctx context.Context, tournamentId string) (models.TournamentSummary, error)
 		// FetchParticipants fetch all participants for a tournament
 		// GET https://api.challonge.com/v2.1/tournaments/{tournaments}/participants.json?page={}&per_page=25
-		FetchParticipants(tournamentId, tournamentGame string) (models.TournamentParticipants, error)
-		// FetchMatches fetch matches for a tournament
-		// GET https://api.challonge.com/v2.1/tournaments/{tournaments}/matches.json?page=1&per_page=25&state=open
-		FetchMatches(tournamentParticipants models.TournamentParticipants) (models.TournamentMatches, error)
+		FetchParticipants(ctx context.Context, tournamentId, tournamentGame string) (models.TournamentParticipants, error)
+		// FetchMatches fetch the matches of a tournament in any of states, every match when states is empty
+		// GET https://api.challonge.com/v2.1/tournaments/{tournaments}/matches.json?page={}&per_page=50&state={}
+		FetchMatches(ctx context.Context, tournamentParticipants models.TournamentParticipants, states []models.MatchState) (models.TournamentMatches, error)
 	}
 )
 
@@ -50,65 +62,103 @@ type (
 // 	}
 // }
 
-func New(baseURL, apiKey string, client *http.Client, contextTimeout time.Duration) *customClient {
-	return &customClient{
-		baseURL: baseURL,
-		client:  client,
-		apiKey:  apiKey,
+func New(baseURL, apiKey string, client *http.Client, contextTimeout time.Duration, opts ...Option) *customClient {
+	c := &customClient{
+		baseURL:        baseURL,
+		client:         client,
+		apiKey:         apiKey,
+		contextTimeout: contextTimeout,
+		retryPolicy:    DefaultRetryPolicy,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-// Return map of type int -> string where int is the tournamentId and string is the game name
-func (c *customClient) FetchTournaments(date string) (map[string]string, error) {
+// WithPageSize asks Challonge for pageSize records per page in place of 25 for tournaments and participants
+// and 50 for matches
+func WithPageSize(pageSize int) Option {
+	return func(c *customClient) {
+		c.pageSize = pageSize
+	}
+}
 
-	resMap := make(map[string]string)
+// perPage is the number of records to ask for per page, defaultSize unless WithPageSize was given
+func (c *customClient) perPage(defaultSize int) int {
+	if c.pageSize > 0 {
+		return c.pageSize
+	}
+	return defaultSize
+}
+
+// Return map of tournamentId -> the tournament's summary
+func (c *customClient) FetchTournaments(ctx context.Context, filter models.TournamentFilter) (map[string]models.TournamentSummary, error) {
+	resMap := make(map[string]models.TournamentSummary)
 
 	// dealing with paginated response
 	paginationLeft := true
 	pageNumber := 1
+	perPage := c.perPage(25)
 
 	for paginationLeft {
 		params := map[string]string{
-			"state":         "in_progress",
-			"created_after": date,
+			"state":         string(filter.TournamentState()),
+			"created_after": filter.CreatedAfter,
 			"page":          strconv.Itoa(pageNumber),
-			"per_page":      "25",
+			"per_page":      strconv.Itoa(perPage),
+		}
+		if filter.CreatedBefore != "" {
+			params["created_before"] = filter.CreatedBefore
 		}
 
-		res, err := c.get(http.MethodGet, c.baseURL+"/tournaments.json", nil, params)
+		res, err := c.get(ctx, http.MethodGet, c.baseURL+"/tournaments.json", nil, params)
 		if err != nil {
 			return nil, err
 		}
 
 		if res.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("%w. %s", ErrResponseNotOK, http.StatusText(res.StatusCode))
+			return nil, newAPIError(res, "")
 		}
 
-		defer res.Body.Close()
-
 		var tournaments models.Tournaments
 		err = json.NewDecoder(res.Body).Decode(&tournaments)
+		res.Body.Close()
 		if err != nil {
-			fmt.Println(err)
 			return nil, fmt.Errorf("%w. %s", err, http.StatusText(http.StatusInternalServerError))
 		}
-		if len(tournaments.Data) == 0 {
-			paginationLeft = false
-		} else {
-			for _, tournament := range tournaments.Data {
-				resMap[tournament.Id] = tournament.Attributes.GameName
-
-			}
 
-			pageNumber++
+		for _, tournament := range tournaments.Data {
+			resMap[tournament.Id] = tournament.Summary()
 		}
+
+		pageNumber, paginationLeft = nextPage(tournaments.Links, tournaments.Meta, pageNumber, perPage, len(tournaments.Data), len(resMap))
 	}
 
 	return resMap, nil
 }
 
+// Return the summary of a single tournament, whatever its state or creation date
+func (c *customClient) FetchTournament(ctx context.Context, tournamentId string) (models.TournamentSummary, error) {
+	res, err := c.get(ctx, http.MethodGet, c.baseURL+"/tournaments/"+tournamentId+".json", nil, nil)
+	if err != nil {
+		return models.TournamentSummary{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return models.TournamentSummary{}, newAPIError(res, tournamentId)
+	}
+
+	var tournament models.TournamentResponse
+	if err := json.NewDecoder(res.Body).Decode(&tournament); err != nil {
+		return models.TournamentSummary{}, fmt.Errorf("%w. %s", err, http.StatusText(http.StatusInternalServerError))
+	}
+	return tournament.Data.Summary(), nil
+}
+
 // Return a models.TournamentParticipants with a map of participants ids -> participant tags
-func (c *customClient) FetchParticipants(tournamentId, tournamentGame string) (models.TournamentParticipants, error) {
+func (c *customClient) FetchParticipants(ctx context.Context, tournamentId, tournamentGame string) (models.TournamentParticipants, error) {
 	participants := models.TournamentParticipants{
 		GameName:     tournamentGame,
 		TournamentID: tournamentId,
@@ -118,90 +168,159 @@ func (c *customClient) FetchParticipants(tournamentId, tournamentGame string) (m
 	// dealing with paginated responses
 	paginationLeft := true
 	pageNumber := 1
+	perPage := c.perPage(25)
 
 	for paginationLeft {
 		params := map[string]string{
 			"page":     strconv.Itoa(pageNumber),
-			"per_page": "25",
+			"per_page": strconv.Itoa(perPage),
 		}
 
-		res, err := c.get(http.MethodGet, c.baseURL+"/tournaments/"+tournamentId+"/participants.json", nil, params)
+		res, err := c.get(ctx, http.MethodGet, c.baseURL+"/tournaments/"+tournamentId+"/participants.json", nil, params)
 		if err != nil {
 			return models.TournamentParticipants{}, err
 		}
 
 		if res.StatusCode != http.StatusOK {
-			return models.TournamentParticipants{}, fmt.Errorf("%w. %s", ErrResponseNotOK, http.StatusText(res.StatusCode))
+			return models.TournamentParticipants{}, newAPIError(res, tournamentId)
 		}
 
-		defer res.Body.Close()
-
 		var participantsChall models.Participants
 		err = json.NewDecoder(res.Body).Decode(&participantsChall)
+		res.Body.Close()
 		if err != nil {
 			return models.TournamentParticipants{}, fmt.Errorf("%w. %s", err, http.StatusText(http.StatusInternalServerError))
 		}
 
-		if len(participantsChall.Data) == 0 {
-			paginationLeft = false
-		} else {
-			for _, participant := range participantsChall.Data {
-				participants.Participant[participant.Id] = participant.Attributes.Name
+		for _, participant := range participantsChall.Data {
+			participants.Participant[participant.Id] = participant.Attributes.Name
+			if username := participant.Attributes.Username; username != nil && *username != "" {
+				if participants.Usernames == nil {
+					participants.Usernames = map[string]string{}
+				}
+				participants.Usernames[participant.Id] = *username
 			}
-			pageNumber++
 		}
+
+		pageNumber, paginationLeft = nextPage(participantsChall.Links, participantsChall.Meta, pageNumber, perPage, len(participantsChall.Data), len(participants.Participant))
 	}
 
 	return participants, nil
 }
 
 // Return a models.TournamentMatches with a list of Match structs that include player names
-func (c *customClient) FetchMatches(tournamentParticipants models.TournamentParticipants) (models.TournamentMatches, error) {
+func (c *customClient) FetchMatches(ctx context.Context, tournamentParticipants models.TournamentParticipants, states []models.MatchState) (models.TournamentMatches, error) {
 	matchResult := models.TournamentMatches{
 		GameName:     tournamentParticipants.GameName,
 		TournamentId: tournamentParticipants.TournamentID,
 		MatchList:    []models.Match{},
 	}
 
-	params := map[string]string{
-		"page":     "1",
-		"per_page": "50",
-		"state":    "open",
-	}
+	// names of participants missing from tournamentParticipants and the identifiers of prerequisite matches
+	// are filled in once every page has been read
+	var unknownNames []unknownName
+	identifiers := map[string]string{}
 
-	res, err := c.get(http.MethodGet, c.baseURL+"/tournaments/"+matchResult.TournamentId+"/matches.json", nil, params)
-	if err != nil {
-		return models.TournamentMatches{}, err
-	}
+	// Challonge only filters on a single state, several are filtered here
+	filterStates := len(states) > 1
 
-	if res.StatusCode != http.StatusOK {
-		return models.TournamentMatches{}, fmt.Errorf("%w. %s", ErrResponseNotOK, http.StatusText(res.StatusCode))
-	}
+	// dealing with paginated responses
+	paginationLeft := true
+	pageNumber := 1
+	received := 0
+	perPage := c.perPage(50)
 
-	defer res.Body.Close()
-	var matches models.Matches
-	err = json.NewDecoder(res.Body).Decode(&matches)
-	if err != nil {
-		return models.TournamentMatches{}, fmt.Errorf("%w. %s", err, http.StatusText(http.StatusInternalServerError))
-	}
+	for paginationLeft {
+		params := map[string]string{
+			"page":     strconv.Itoa(pageNumber),
+			"per_page": strconv.Itoa(perPage),
+		}
+		if len(states) == 1 {
+			params["state"] = string(states[0])
+		}
 
-	stationsMap := getStationsMap(matches)
+		res, err := c.get(ctx, http.MethodGet, c.baseURL+"/tournaments/"+matchResult.TournamentId+"/matches.json", nil, params)
+		if err != nil {
+			return models.TournamentMatches{}, err
+		}
 
-	if len(matches.Data) == 0 {
-		return matchResult, nil
-	}
+		if res.StatusCode != http.StatusOK {
+			return models.TournamentMatches{}, newAPIError(res, matchResult.TournamentId)
+		}
 
-	for _, match := range matches.Data {
-		matchData := models.Match{
-			Id:                 match.Id,
-			Player1Name:        tournamentParticipants.Participant[strconv.Itoa(match.Attributes.PointsByParticipant[0].ParticipantId)],
-			Player2Name:        tournamentParticipants.Participant[strconv.Itoa(match.Attributes.PointsByParticipant[1].ParticipantId)],
-			Round:              match.Attributes.Round,
-			SuggestedPlayOrder: match.Attributes.SuggestedPlayOrder,
-			Underway:           !match.Attributes.Timestamps.UnderwayAt.IsZero(),
-			Station:            stationsMap[match.Relationship.Station.Data.Id],
+		var matches models.Matches
+		err = json.NewDecoder(res.Body).Decode(&matches)
+		res.Body.Close()
+		if err != nil {
+			return models.TournamentMatches{}, fmt.Errorf("%w. %s", err, http.StatusText(http.StatusInternalServerError))
+		}
+
+		// stations are included per page alongside the matches that reference them
+		stationsMap := getStationsMap(matches)
+
+		for _, match := range matches.Data {
+			identifiers[match.Id] = match.Attributes.Identifier
+			if filterStates && !slices.Contains(states, match.Attributes.State) {
+				continue
+			}
+			matchData := models.Match{
+				Id:                 match.Id,
+				Identifier:         match.Attributes.Identifier,
+				State:              match.Attributes.State,
+				GroupId:            optionalId(match.Attributes.GroupId),
+				Round:              match.Attributes.Round,
+				SuggestedPlayOrder: match.Attributes.SuggestedPlayOrder,
+				Underway:           !match.Attributes.Timestamps.UnderwayAt.IsZero(),
+				Station:            stationsMap[match.Relationship.Station.Data.Id],
+				Scores:             match.Attributes.Scores,
+				ScoreInSets:        match.Attributes.ScoreInSets,
+				Tie:                match.Attributes.Tie,
+				StartedAt:          optionalTime(match.Attributes.Timestamps.StartedAt),
+				UnderwayAt:         optionalTime(match.Attributes.Timestamps.UnderwayAt),
+				UpdatedAt:          optionalTime(match.Attributes.Timestamps.UpdatedAt),
+			}
+			if matchData.ScoreInSets == nil {
+				matchData.ScoreInSets = [][]int{}
+			}
+			for player, slot := range matchSlots(match.Attributes) {
+				if winnerId := match.Attributes.WinnerId; winnerId != nil && slot.participantId != 0 && *winnerId == slot.participantId {
+					matchData.Winner = player + 1
+				}
+				name, prerequisite := &matchData.Player1Name, &matchData.Player1PrerequisiteMatch
+				if player == 1 {
+					name, prerequisite = &matchData.Player2Name, &matchData.Player2PrerequisiteMatch
+				}
+				switch {
+				case slot.participantId != 0:
+					participantId := strconv.Itoa(slot.participantId)
+					participantName, ok := tournamentParticipants.Participant[participantId]
+					if !ok {
+						unknownNames = append(unknownNames, unknownName{match: len(matchResult.MatchList), player: player, participantId: participantId})
+					}
+					*name = participantName
+				case slot.prereqMatchId != nil:
+					*name = models.PlayerTBD
+					*prerequisite = &models.PrerequisiteMatch{
+						MatchId: strconv.Itoa(*slot.prereqMatchId),
+						Loser:   slot.prereqLoser,
+					}
+				default:
+					*name = models.PlayerBye
+				}
+			}
+			matchResult.MatchList = append(matchResult.MatchList, matchData)
 		}
-		matchResult.MatchList = append(matchResult.MatchList, matchData)
+
+		received += len(matches.Data)
+		pageNumber, paginationLeft = nextPage(matches.Links, matches.Meta, pageNumber, perPage, len(matches.Data), received)
+	}
+
+	if len(unknownNames) > 0 {
+		c.resolveUnknownNames(ctx, &matchResult, unknownNames)
+	}
+	for _, match := range matchResult.MatchList {
+		describePrerequisite(match.Player1PrerequisiteMatch, identifiers)
+		describePrerequisite(match.Player2PrerequisiteMatch, identifiers)
 	}
 
 	// sort matches based on SuggestedPlayOrder
@@ -212,8 +331,155 @@ func (c *customClient) FetchMatches(tournamentParticipants models.TournamentPart
 	return matchResult, nil
 }
 
-func (c *customClient) get(method, urlPath string, reqBody io.Reader, params map[string]string) (resp *http.Response, err error) {
-	req, err := http.NewRequest(method, urlPath, reqBody)
+// matchSlot is one of the two players of a match, either a participant or the match they are still waiting on
+type matchSlot struct {
+	participantId int
+	prereqMatchId *int
+	prereqLoser   bool
+}
+
+// matchSlots returns both player slots of a match. Challonge leaves undecided players out of points_by_participant,
+// so a lone participant goes in the slot that isn't waiting on a prerequisite match
+func matchSlots(attributes models.MatchAttributes) [2]matchSlot {
+	slots := [2]matchSlot{
+		{prereqMatchId: attributes.Player1PrereqMatchId, prereqLoser: attributes.Player1IsPrereqMatchLoser},
+		{prereqMatchId: attributes.Player2PrereqMatchId, prereqLoser: attributes.Player2IsPrereqMatchLoser},
+	}
+	points := attributes.PointsByParticipant
+	switch {
+	case len(points) >= 2:
+		slots[0].participantId = points[0].ParticipantId
+		slots[1].participantId = points[1].ParticipantId
+	case len(points) == 1 && slots[0].prereqMatchId != nil && slots[1].prereqMatchId == nil:
+		slots[1].participantId = points[0].ParticipantId
+	case len(points) == 1:
+		slots[0].participantId = points[0].ParticipantId
+	}
+	return slots
+}
+
+// optionalTime returns nil for a timestamp Challonge did not set
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// optionalId formats an id Challonge may send as null, returning "" for null
+func optionalId(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(*id)
+}
+
+// describePrerequisite names the prerequisite match by its bracket identifier when it is in identifiers
+func describePrerequisite(prerequisite *models.PrerequisiteMatch, identifiers map[string]string) {
+	if prerequisite == nil {
+		return
+	}
+	prerequisite.Identifier = identifiers[prerequisite.MatchId]
+	outcome := "Winner"
+	if prerequisite.Loser {
+		outcome = "Loser"
+	}
+	name := prerequisite.Identifier
+	if name == "" {
+		name = prerequisite.MatchId
+	}
+	prerequisite.Description = fmt.Sprintf("%s of match %s", outcome, name)
+}
+
+// unknownName is a player slot of a match whose participant was not in the participants it was looked up in
+type unknownName struct {
+	match         int
+	player        int
+	participantId string
+}
+
+// resolveUnknownNames fetches the participants missing from the roster one by one and fills in their names.
+// Participants that still can't be found are left unnamed and listed in matchResult.UnknownParticipantIds
+func (c *customClient) resolveUnknownNames(ctx context.Context, matchResult *models.TournamentMatches, unknownNames []unknownName) {
+	names := map[string]string{}
+	for _, unknown := range unknownNames {
+		if _, ok := names[unknown.participantId]; ok || slices.Contains(matchResult.UnknownParticipantIds, unknown.participantId) {
+			continue
+		}
+		participant, err := c.fetchParticipant(ctx, matchResult.TournamentId, unknown.participantId)
+		if err != nil {
+			slog.Warn("challonge participant could not be resolved", "tournament", matchResult.TournamentId, "participant", unknown.participantId, "error", err)
+			matchResult.UnknownParticipantIds = append(matchResult.UnknownParticipantIds, unknown.participantId)
+			continue
+		}
+		names[unknown.participantId] = participant.Attributes.Name
+	}
+	slog.Info("challonge participants missing from cache", "tournament", matchResult.TournamentId, "resolved", len(names), "unresolved", len(matchResult.UnknownParticipantIds))
+
+	for _, unknown := range unknownNames {
+		name := names[unknown.participantId]
+		if unknown.player == 0 {
+			matchResult.MatchList[unknown.match].Player1Name = name
+		} else {
+			matchResult.MatchList[unknown.match].Player2Name = name
+		}
+	}
+	if len(names) > 0 {
+		matchResult.ResolvedParticipants = names
+	}
+}
+
+// fetchParticipant fetches a single participant of a tournament
+// GET https://api.challonge.com/v2.1/tournaments/{tournament}/participants/{participant}.json
+func (c *customClient) fetchParticipant(ctx context.Context, tournamentId, participantId string) (models.Participant, error) {
+	res, err := c.get(ctx, http.MethodGet, c.baseURL+"/tournaments/"+tournamentId+"/participants/"+participantId+".json", nil, nil)
+	if err != nil {
+		return models.Participant{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return models.Participant{}, newAPIError(res, tournamentId)
+	}
+
+	var participant models.ParticipantResponse
+	if err := json.NewDecoder(res.Body).Decode(&participant); err != nil {
+		return models.Participant{}, fmt.Errorf("%w. %s", err, http.StatusText(http.StatusInternalServerError))
+	}
+	return participant.Data, nil
+}
+
+// nextPage returns the page to request after pageNumber and whether there is one left to fetch.
+// Challonge's next link drops the filter params and is sent even from the last page, so only its
+// page number is used. A page holding fewer than the perPage records asked for is the last one, and
+// the count in meta, which ignores a state filter, is trusted over the link once every record has been received.
+func nextPage(links models.Links, meta models.Meta, pageNumber, perPage, pageSize, received int) (int, bool) {
+	if pageSize < perPage || pageSize == 0 || links.Next == "" {
+		return pageNumber, false
+	}
+	if meta.Count > 0 && received >= meta.Count {
+		return pageNumber, false
+	}
+
+	next, err := url.Parse(links.Next)
+	if err != nil {
+		return pageNumber, false
+	}
+	nextPageNumber, err := strconv.Atoi(next.Query().Get("page"))
+	if err != nil || nextPageNumber <= pageNumber {
+		return pageNumber, false
+	}
+	return nextPageNumber, true
+}
+
+// Timeout is the configured timeout of a whole refresh, from listing the tournaments to their last participant.
+// It is applied by the caller around every fetch making up a refresh rather than to each fetch on its own
+func (c *customClient) Timeout() time.Duration {
+	return c.contextTimeout
+}
+
+func (c *customClient) get(ctx context.Context, method, urlPath string, reqBody io.Reader, params map[string]string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, method, urlPath, reqBody)
 	if err != nil {
 		// gracefully handle error and pass along
 		return nil, err
@@ -230,7 +496,7 @@ func (c *customClient) get(method, urlPath string, reqBody io.Reader, params map
 	}
 	req.URL.RawQuery = q.Encode()
 
-	return c.client.Do(req)
+	return c.do(req)
 }
 
 // Return a map of station id(string) -> station name(string)
@@ -1,13 +1,19 @@
 package challongebracketmatches
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -16,7 +22,13 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-var server *httptest.Server
+var (
+	server            *httptest.Server
+	flakyRequestCount atomic.Int32
+	pageRequestCount  atomic.Int32
+	slowInFlight      atomic.Int32
+	slowMaxInFlight   atomic.Int32
+)
 
 const MOCK_API_KEY = "mock api key"
 
@@ -29,6 +41,14 @@ func TestMain(m *testing.M) {
 		// mock endpoint for get tournaments
 		case "/tournaments.json":
 			mockFetchTournamentEndpoint(w, r)
+		// mock endpoint for get a single tournament
+		case "/tournaments/1.json":
+			if !testApiKeyAuth(r.Header.Get("Authorization")) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			byteValue, _ := readJsonFile("./mock-api-responses/mock-tournament-single-response.json")
+			w.Write(byteValue)
 		// mock endpoint for get participants
 		case "/tournaments/1234/participants.json":
 			mockFetchParticipantEndpoint(w, r)
@@ -36,11 +56,51 @@ func TestMain(m *testing.M) {
 			mockFetchParticipantEndpoint(w, r)
 		case "/tournaments/112358/participants.json":
 			mockFetchParticipantEndpoint(w, r)
+		case "/tournaments/7890/participants.json":
+			mockFetchParticipantEndpoint(w, r)
+		// mock endpoint for get a single participant, 6 has been removed from the tournament
+		case "/tournaments/1234/participants/5.json":
+			byteValue, _ := readJsonFile("./mock-api-responses/mock-participant-single-response.json")
+			w.Write(byteValue)
+		case "/tournaments/1234/participants/6.json":
+			w.WriteHeader(http.StatusNotFound)
 		// mock endpoint for get matches
 		case "/tournaments/1234/matches.json":
 			mockFetchMatchesEndpoint(w, r)
 		case "/tournaments/2234/matches.json":
 			mockFetchMatchesEndpoint(w, r)
+		case "/tournaments/3234/matches.json":
+			mockFetchMatchesEndpoint(w, r)
+		case "/tournaments/4234/matches.json":
+			mockFetchMatchesEndpoint(w, r)
+		// mock endpoints that fail before responding ok
+		case "/tournaments/5555/matches.json":
+			mockFlakyEndpoint(w, r, http.StatusBadGateway)
+		case "/tournaments/6666/matches.json":
+			w.Header().Set("Retry-After", "0")
+			mockFlakyEndpoint(w, r, http.StatusTooManyRequests)
+		case "/tournaments/7777/matches.json":
+			flakyRequestCount.Add(1)
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/tournaments/7778/matches.json":
+			flakyRequestCount.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		// mock endpoint that counts its requests and answers each page with the captured matches response
+		case "/tournaments/1111/matches.json":
+			pageRequestCount.Add(1)
+			byteValue, _ := readJsonFile("./mock-api-responses/mock-matches-response.json")
+			w.Write(byteValue)
+		// mock endpoint for a deleted tournament
+		case "/tournaments/4040/matches.json":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[{"status":"404","title":"Not Found","detail":"Tournament not found"}]}`))
+		// mock endpoint that records how many requests it is serving at once
+		case "/tournaments/8888/matches.json":
+			mockSlowEndpoint(w, r)
+		// mock endpoint that never responds until the request is cancelled
+		case "/tournaments/9999/matches.json":
+			<-r.Context().Done()
 		default:
 			http.NotFoundHandler().ServeHTTP(w, r)
 		}
@@ -53,9 +113,11 @@ func TestMain(m *testing.M) {
 func TestCreateCustomClient(t *testing.T) {
 	// Given
 	givenCustomClient := &customClient{
-		baseURL: "testEndpoint",
-		client:  http.DefaultClient,
-		apiKey:  "1234567890",
+		baseURL:        "testEndpoint",
+		client:         http.DefaultClient,
+		apiKey:         "1234567890",
+		contextTimeout: 20,
+		retryPolicy:    DefaultRetryPolicy,
 	}
 	// When
 	res := New("testEndpoint", "1234567890", http.DefaultClient, 20)
@@ -65,57 +127,148 @@ func TestCreateCustomClient(t *testing.T) {
 
 func TestFetchTournaments(t *testing.T) {
 	// Given
+	startsAt := time.Date(2023, 7, 16, 18, 0, 0, 0, time.UTC)
+	mockSingleTournament := models.TournamentSummary{
+		Id:               "1",
+		Name:             "testName",
+		URL:              "test_bracket",
+		GameName:         "test",
+		TournamentType:   "double elimination",
+		State:            models.TournamentStateInProgress,
+		Participants:     16,
+		StartsAt:         &startsAt,
+		FullChallongeURL: "https://challonge.com/test_bracket",
+	}
 	tt := []struct {
 		testName      string
-		mockDate      string
+		mockFilter    models.TournamentFilter
 		mockFetchData FetchData
-		wantData      map[string]string
+		wantData      map[string]models.TournamentSummary
 		wantErr       error
 	}{
 		{
 			testName:      "response not ok, auth error",
-			mockDate:      time.Now().Local().Format("2006-01-02"),
+			mockFilter:    models.DateFilter(time.Now().Local().Format("2006-01-02")),
 			mockFetchData: New(server.URL, "bad api key", http.DefaultClient, 5*time.Second),
 			wantData:      nil,
-			wantErr:       fmt.Errorf("%w. %s", ErrResponseNotOK, http.StatusText(http.StatusUnauthorized)),
+			wantErr:       &APIError{StatusCode: http.StatusUnauthorized, Endpoint: "/tournaments.json"},
 		},
 		{
 			testName:      "response ok but no values",
-			mockDate:      "2022-07-16",
+			mockFilter:    models.DateFilter("2022-07-16"),
 			mockFetchData: New(server.URL, "mock api key", http.DefaultClient, 5*time.Second),
-			wantData:      map[string]string{},
+			wantData:      map[string]models.TournamentSummary{},
 			wantErr:       nil,
 		},
 		{
 			testName:      "response ok one tournament no pagination",
-			mockDate:      "2023-07-16",
+			mockFilter:    models.DateFilter("2023-07-16"),
 			mockFetchData: New(server.URL, "mock api key", http.DefaultClient, 5*time.Second),
-			wantData: map[string]string{
-				"1": "test",
+			wantData: map[string]models.TournamentSummary{
+				"1": mockSingleTournament,
 			},
 			wantErr: nil,
 		},
 		{
 			testName:      "response ok multiple tournament no pagination",
-			mockDate:      "2023-07-17",
+			mockFilter:    models.DateFilter("2023-07-17"),
 			mockFetchData: New(server.URL, "mock api key", http.DefaultClient, 5*time.Second),
-			wantData: map[string]string{
-				"1": "test",
-				"2": "test2",
+			wantData: map[string]models.TournamentSummary{
+				"1": mockTournamentSummary("1", "test"),
+				"2": mockTournamentSummary("2", "test2"),
 			},
 			wantErr: nil,
 		},
 		{
 			testName:      "response ok multiple tournament and pagination",
-			mockDate:      "2023-07-18",
+			mockFilter:    models.DateFilter("2023-07-18"),
+			mockFetchData: New(server.URL, "mock api key", http.DefaultClient, 5*time.Second, WithPageSize(2)),
+			wantData: map[string]models.TournamentSummary{
+				"1": mockTournamentSummary("1", "test"),
+				"2": mockTournamentSummary("2", "test2"),
+				"3": mockTournamentSummary("3", "test3"),
+				"4": mockTournamentSummary("4", "test4"),
+				"5": mockTournamentSummary("5", "test5"),
+				"6": mockTournamentSummary("6", "test6"),
+			},
+			wantErr: nil,
+		},
+		{
+			testName: "response ok pending tournaments inside a date window",
+			mockFilter: models.TournamentFilter{
+				CreatedAfter:  "2023-07-19",
+				CreatedBefore: "2023-07-21",
+				State:         models.TournamentStatePending,
+			},
+			mockFetchData: New(server.URL, "mock api key", http.DefaultClient, 5*time.Second),
+			wantData: map[string]models.TournamentSummary{
+				"1": mockSingleTournament,
+			},
+			wantErr: nil,
+		},
+		{
+			testName:      "response ok but no in progress tournaments inside the date window",
+			mockFilter:    models.DateFilter("2023-07-19"),
 			mockFetchData: New(server.URL, "mock api key", http.DefaultClient, 5*time.Second),
-			wantData: map[string]string{
-				"1": "test",
-				"2": "test2",
-				"3": "test3",
-				"4": "test4",
-				"5": "test5",
-				"6": "test6",
+			wantData:      map[string]models.TournamentSummary{},
+			wantErr:       nil,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When
+			gotData, gotErr := tc.mockFetchData.FetchTournaments(context.Background(), tc.mockFilter)
+
+			//Then
+			require.Equal(t, tc.wantData, gotData)
+			if tc.wantErr != nil {
+				require.EqualError(t, gotErr, tc.wantErr.Error())
+			} else {
+				require.NoError(t, gotErr)
+			}
+		})
+	}
+}
+
+func TestFetchTournament(t *testing.T) {
+	// Given
+	startsAt := time.Date(2023, 7, 16, 18, 0, 0, 0, time.UTC)
+	tt := []struct {
+		testName         string
+		mockTournamentId string
+		mockFetchData    FetchData
+		wantData         models.TournamentSummary
+		wantErr          error
+	}{
+		{
+			testName:         "response not ok, auth error",
+			mockTournamentId: "1",
+			mockFetchData:    New(server.URL, "bad api key", http.DefaultClient, 5*time.Second),
+			wantData:         models.TournamentSummary{},
+			wantErr:          &APIError{StatusCode: http.StatusUnauthorized, Endpoint: "/tournaments/1.json", TournamentID: "1"},
+		},
+		{
+			testName:         "response not ok, tournament not found",
+			mockTournamentId: "2",
+			mockFetchData:    New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second),
+			wantData:         models.TournamentSummary{},
+			wantErr:          &APIError{StatusCode: http.StatusNotFound, Endpoint: "/tournaments/2.json", TournamentID: "2"},
+		},
+		{
+			testName:         "response ok",
+			mockTournamentId: "1",
+			mockFetchData:    New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second),
+			wantData: models.TournamentSummary{
+				Id:               "1",
+				Name:             "testName",
+				URL:              "test_bracket",
+				GameName:         "test",
+				TournamentType:   "double elimination",
+				State:            models.TournamentStateInProgress,
+				Participants:     16,
+				StartsAt:         &startsAt,
+				FullChallongeURL: "https://challonge.com/test_bracket",
 			},
 			wantErr: nil,
 		},
@@ -124,7 +277,7 @@ func TestFetchTournaments(t *testing.T) {
 	for _, tc := range tt {
 		t.Run(tc.testName, func(t *testing.T) {
 			// When
-			gotData, gotErr := tc.mockFetchData.FetchTournaments(tc.mockDate)
+			gotData, gotErr := tc.mockFetchData.FetchTournament(context.Background(), tc.mockTournamentId)
 
 			//Then
 			require.Equal(t, tc.wantData, gotData)
@@ -159,7 +312,7 @@ func TestFetchParticipants(t *testing.T) {
 				tournamentGame: "testGameName",
 			},
 			wantData: models.TournamentParticipants{},
-			wantErr:  fmt.Errorf("%w. %s", ErrResponseNotOK, http.StatusText(http.StatusUnauthorized)),
+			wantErr:  &APIError{StatusCode: http.StatusUnauthorized, Endpoint: "/tournaments/1234/participants.json", TournamentID: "1234"},
 		},
 		{
 			testName:      "response ok but no values",
@@ -202,7 +355,7 @@ func TestFetchParticipants(t *testing.T) {
 		},
 		{
 			testName:      "data found pagination",
-			mockFetchData: New(server.URL, "mock api key", http.DefaultClient, 5*time.Second),
+			mockFetchData: New(server.URL, "mock api key", http.DefaultClient, 5*time.Second, WithPageSize(2)),
 			inputData: struct {
 				tournamentId   string
 				tournamentGame string
@@ -225,13 +378,33 @@ func TestFetchParticipants(t *testing.T) {
 				},
 			},
 		},
+		{
+			testName:      "usernames of participants with a challonge account",
+			mockFetchData: New(server.URL, "mock api key", http.DefaultClient, 5*time.Second),
+			inputData: struct {
+				tournamentId   string
+				tournamentGame string
+			}{
+				tournamentId:   "7890",
+				tournamentGame: "test",
+			},
+			wantData: models.TournamentParticipants{
+				GameName:     "test",
+				TournamentID: "7890",
+				Participant: map[string]string{
+					"1": "testName1",
+					"2": "testName2",
+				},
+				Usernames: map[string]string{"1": "testUser1"},
+			},
+		},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.testName, func(t *testing.T) {
 			// t.Parallel()
 
-			gotData, gotErr := tc.mockFetchData.FetchParticipants(tc.inputData.tournamentId, tc.inputData.tournamentGame)
+			gotData, gotErr := tc.mockFetchData.FetchParticipants(context.Background(), tc.inputData.tournamentId, tc.inputData.tournamentGame)
 			assert.Equal(t, tc.wantData, gotData)
 			if tc.wantErr != nil {
 				assert.EqualError(t, gotErr, tc.wantErr.Error())
@@ -247,6 +420,7 @@ func TestFetchMatches(t *testing.T) {
 		testName      string
 		mockFetchData FetchData
 		inputData     models.TournamentParticipants
+		inputStates   []models.MatchState
 		wantData      models.TournamentMatches
 		wantErr       error
 	}{
@@ -258,7 +432,7 @@ func TestFetchMatches(t *testing.T) {
 				TournamentID: "1234",
 			},
 			wantData: models.TournamentMatches{},
-			wantErr:  fmt.Errorf("%w. %s", ErrResponseNotOK, http.StatusText(http.StatusUnauthorized)),
+			wantErr:  &APIError{StatusCode: http.StatusUnauthorized, Endpoint: "/tournaments/1234/matches.json", TournamentID: "1234"},
 		},
 		{
 			testName:      "response ok but no matches",
@@ -303,30 +477,422 @@ func TestFetchMatches(t *testing.T) {
 				MatchList: []models.Match{
 					{
 						Id:                 "345160410",
+						Identifier:         "A",
+						State:              models.MatchStateOpen,
+						Player1Name:        "testName1",
+						Player2Name:        "testName2",
+						Round:              1,
+						SuggestedPlayOrder: 1,
+						Underway:           true,
+						Station:            "TestStation1",
+						Scores:             "0 - 0",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.552Z"),
+						UnderwayAt:         mockTime("2023-11-25T14:57:06.247Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:57:06.252Z"),
+					},
+					{
+						Id:                 "345160411",
+						Identifier:         "B",
+						State:              models.MatchStateOpen,
+						Player1Name:        "testName3",
+						Player2Name:        "testName4",
+						Round:              1,
+						SuggestedPlayOrder: 2,
+						Underway:           false,
+						Station:            "TestStation2",
+						Scores:             "0 - 0",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.616Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:46:41.630Z"),
+					},
+					{
+						Id:                 "345160413",
+						Identifier:         "D",
+						State:              models.MatchStateOpen,
+						Player1Name:        "testName5",
+						Player2Name:        "testName6",
+						Round:              1,
+						SuggestedPlayOrder: 4,
+						Underway:           false,
+						Station:            "",
+						Scores:             "0 - 0",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.753Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:46:41.753Z"),
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			testName:      "response ok with unknown participants",
+			mockFetchData: New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second),
+			inputData: models.TournamentParticipants{
+				GameName:     "test",
+				TournamentID: "1234",
+				Participant: map[string]string{
+					"1": "testName1",
+					"2": "testName2",
+					"3": "testName3",
+					"4": "testName4",
+				},
+			},
+			wantData: models.TournamentMatches{
+				GameName:     "test",
+				TournamentId: "1234",
+				MatchList: []models.Match{
+					{
+						Id:                 "345160410",
+						Identifier:         "A",
+						State:              models.MatchStateOpen,
+						Player1Name:        "testName1",
+						Player2Name:        "testName2",
+						Round:              1,
+						SuggestedPlayOrder: 1,
+						Underway:           true,
+						Station:            "TestStation1",
+						Scores:             "0 - 0",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.552Z"),
+						UnderwayAt:         mockTime("2023-11-25T14:57:06.247Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:57:06.252Z"),
+					},
+					{
+						Id:                 "345160411",
+						Identifier:         "B",
+						State:              models.MatchStateOpen,
+						Player1Name:        "testName3",
+						Player2Name:        "testName4",
+						Round:              1,
+						SuggestedPlayOrder: 2,
+						Underway:           false,
+						Station:            "TestStation2",
+						Scores:             "0 - 0",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.616Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:46:41.630Z"),
+					},
+					{
+						Id:                 "345160413",
+						Identifier:         "D",
+						State:              models.MatchStateOpen,
+						Player1Name:        "testName5",
+						Player2Name:        "",
+						Round:              1,
+						SuggestedPlayOrder: 4,
+						Underway:           false,
+						Station:            "",
+						Scores:             "0 - 0",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.753Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:46:41.753Z"),
+					},
+				},
+				UnknownParticipantIds: []string{"6"},
+				ResolvedParticipants:  map[string]string{"5": "testName5"},
+			},
+			wantErr: nil,
+		},
+		{
+			testName:      "response ok with group stage, TBD and bye slots",
+			mockFetchData: New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second),
+			inputData: models.TournamentParticipants{
+				GameName:     "test",
+				TournamentID: "4234",
+				Participant: map[string]string{
+					"1": "testName1",
+					"2": "testName2",
+					"3": "testName3",
+					"4": "testName4",
+				},
+			},
+			wantData: models.TournamentMatches{
+				GameName:     "test",
+				TournamentId: "4234",
+				MatchList: []models.Match{
+					{
+						Id:                 "1001",
+						Identifier:         "A1",
+						State:              models.MatchStateOpen,
+						GroupId:            "5",
+						Player1Name:        "testName1",
+						Player2Name:        "testName2",
+						Round:              1,
+						SuggestedPlayOrder: 1,
+						Scores:             "",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.552Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:57:06.252Z"),
+					},
+					{
+						Id:                       "1002",
+						Identifier:               "B",
+						State:                    models.MatchStatePending,
+						Player1Name:              models.PlayerTBD,
+						Player2Name:              "testName3",
+						Player1PrerequisiteMatch: &models.PrerequisiteMatch{MatchId: "1001", Identifier: "A1", Description: "Winner of match A1"},
+						Round:                    1,
+						SuggestedPlayOrder:       2,
+						Scores:                   "",
+						ScoreInSets:              [][]int{},
+						UpdatedAt:                mockTime("2023-11-25T14:57:06.252Z"),
+					},
+					{
+						Id:                       "1003",
+						Identifier:               "C",
+						State:                    models.MatchStatePending,
+						Player1Name:              models.PlayerTBD,
+						Player2Name:              models.PlayerTBD,
+						Player1PrerequisiteMatch: &models.PrerequisiteMatch{MatchId: "1001", Identifier: "A1", Loser: true, Description: "Loser of match A1"},
+						Player2PrerequisiteMatch: &models.PrerequisiteMatch{MatchId: "999", Description: "Winner of match 999"},
+						Round:                    1,
+						SuggestedPlayOrder:       3,
+						Scores:                   "",
+						ScoreInSets:              [][]int{},
+						UpdatedAt:                mockTime("2023-11-25T14:57:06.252Z"),
+					},
+					{
+						Id:                 "1004",
+						Identifier:         "D",
+						State:              models.MatchStateOpen,
+						Player1Name:        "testName4",
+						Player2Name:        models.PlayerBye,
+						Round:              1,
+						SuggestedPlayOrder: 4,
+						Scores:             "",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.552Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:57:06.252Z"),
+					},
+					{
+						Id:                 "1005",
+						Identifier:         "E",
+						State:              models.MatchStateComplete,
+						Player1Name:        "testName1",
+						Player2Name:        "testName3",
+						Round:              1,
+						SuggestedPlayOrder: 5,
+						Underway:           true,
+						Scores:             "2 - 1",
+						ScoreInSets:        [][]int{{2, 1}},
+						Winner:             1,
+						StartedAt:          mockTime("2023-11-25T14:46:41.552Z"),
+						UnderwayAt:         mockTime("2023-11-25T14:58:00.000Z"),
+						UpdatedAt:          mockTime("2023-11-25T15:10:00.000Z"),
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			testName:      "response ok with a single state filtered by challonge",
+			mockFetchData: New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second),
+			inputData: models.TournamentParticipants{
+				GameName:     "test",
+				TournamentID: "4234",
+				Participant: map[string]string{
+					"1": "testName1",
+					"2": "testName2",
+					"3": "testName3",
+					"4": "testName4",
+				},
+			},
+			inputStates: []models.MatchState{models.MatchStateOpen},
+			wantData: models.TournamentMatches{
+				GameName:     "test",
+				TournamentId: "4234",
+				MatchList: []models.Match{
+					{
+						Id:                 "1001",
+						Identifier:         "A1",
+						State:              models.MatchStateOpen,
+						GroupId:            "5",
+						Player1Name:        "testName1",
+						Player2Name:        "testName2",
+						Round:              1,
+						SuggestedPlayOrder: 1,
+						Scores:             "",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.552Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:57:06.252Z"),
+					},
+					{
+						Id:                 "1004",
+						Identifier:         "D",
+						State:              models.MatchStateOpen,
+						Player1Name:        "testName4",
+						Player2Name:        models.PlayerBye,
+						Round:              1,
+						SuggestedPlayOrder: 4,
+						Scores:             "",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.552Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:57:06.252Z"),
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			testName:      "response ok with several states",
+			mockFetchData: New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second),
+			inputData: models.TournamentParticipants{
+				GameName:     "test",
+				TournamentID: "4234",
+				Participant: map[string]string{
+					"1": "testName1",
+					"2": "testName2",
+					"3": "testName3",
+					"4": "testName4",
+				},
+			},
+			inputStates: []models.MatchState{models.MatchStateComplete, models.MatchStatePending},
+			wantData: models.TournamentMatches{
+				GameName:     "test",
+				TournamentId: "4234",
+				MatchList: []models.Match{
+					{
+						Id:                       "1002",
+						Identifier:               "B",
+						State:                    models.MatchStatePending,
+						Player1Name:              models.PlayerTBD,
+						Player2Name:              "testName3",
+						Player1PrerequisiteMatch: &models.PrerequisiteMatch{MatchId: "1001", Identifier: "A1", Description: "Winner of match A1"},
+						Round:                    1,
+						SuggestedPlayOrder:       2,
+						Scores:                   "",
+						ScoreInSets:              [][]int{},
+						UpdatedAt:                mockTime("2023-11-25T14:57:06.252Z"),
+					},
+					{
+						Id:                       "1003",
+						Identifier:               "C",
+						State:                    models.MatchStatePending,
+						Player1Name:              models.PlayerTBD,
+						Player2Name:              models.PlayerTBD,
+						Player1PrerequisiteMatch: &models.PrerequisiteMatch{MatchId: "1001", Identifier: "A1", Loser: true, Description: "Loser of match A1"},
+						Player2PrerequisiteMatch: &models.PrerequisiteMatch{MatchId: "999", Description: "Winner of match 999"},
+						Round:                    1,
+						SuggestedPlayOrder:       3,
+						Scores:                   "",
+						ScoreInSets:              [][]int{},
+						UpdatedAt:                mockTime("2023-11-25T14:57:06.252Z"),
+					},
+					{
+						Id:                 "1005",
+						Identifier:         "E",
+						State:              models.MatchStateComplete,
+						Player1Name:        "testName1",
+						Player2Name:        "testName3",
+						Round:              1,
+						SuggestedPlayOrder: 5,
+						Underway:           true,
+						Scores:             "2 - 1",
+						ScoreInSets:        [][]int{{2, 1}},
+						Winner:             1,
+						StartedAt:          mockTime("2023-11-25T14:46:41.552Z"),
+						UnderwayAt:         mockTime("2023-11-25T14:58:00.000Z"),
+						UpdatedAt:          mockTime("2023-11-25T15:10:00.000Z"),
+					},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			testName:      "response ok with pagination",
+			mockFetchData: New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second, WithPageSize(2)),
+			inputData: models.TournamentParticipants{
+				GameName:     "test",
+				TournamentID: "3234",
+				Participant: map[string]string{
+					"1": "testName1",
+					"2": "testName2",
+					"3": "testName3",
+					"4": "testName4",
+					"5": "testName5",
+					"6": "testName6",
+				},
+			},
+			wantData: models.TournamentMatches{
+				GameName:     "test",
+				TournamentId: "3234",
+				MatchList: []models.Match{
+					{
+						Id:                 "345160410",
+						Identifier:         "A",
+						State:              models.MatchStateOpen,
 						Player1Name:        "testName1",
 						Player2Name:        "testName2",
 						Round:              1,
 						SuggestedPlayOrder: 1,
 						Underway:           true,
 						Station:            "TestStation1",
+						Scores:             "0 - 0",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.552Z"),
+						UnderwayAt:         mockTime("2023-11-25T14:57:06.247Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:57:06.252Z"),
 					},
 					{
 						Id:                 "345160411",
+						Identifier:         "B",
+						State:              models.MatchStateOpen,
 						Player1Name:        "testName3",
 						Player2Name:        "testName4",
 						Round:              1,
 						SuggestedPlayOrder: 2,
 						Underway:           false,
 						Station:            "TestStation2",
+						Scores:             "0 - 0",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.616Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:46:41.630Z"),
 					},
 					{
 						Id:                 "345160413",
+						Identifier:         "D",
+						State:              models.MatchStateOpen,
 						Player1Name:        "testName5",
 						Player2Name:        "testName6",
 						Round:              1,
 						SuggestedPlayOrder: 4,
 						Underway:           false,
 						Station:            "",
+						Scores:             "0 - 0",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.753Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:46:41.753Z"),
+					},
+					{
+						Id:                 "345160414",
+						Identifier:         "E",
+						State:              models.MatchStateOpen,
+						Player1Name:        "testName1",
+						Player2Name:        "testName3",
+						Round:              2,
+						SuggestedPlayOrder: 5,
+						Underway:           false,
+						Station:            "",
+						Scores:             "0 - 0",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.753Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:46:41.753Z"),
+					},
+					{
+						Id:                 "345160415",
+						Identifier:         "F",
+						State:              models.MatchStateOpen,
+						Player1Name:        "testName2",
+						Player2Name:        "testName4",
+						Round:              2,
+						SuggestedPlayOrder: 6,
+						Underway:           false,
+						Station:            "",
+						Scores:             "0 - 0",
+						ScoreInSets:        [][]int{},
+						StartedAt:          mockTime("2023-11-25T14:46:41.753Z"),
+						UpdatedAt:          mockTime("2023-11-25T14:46:41.753Z"),
 					},
 				},
 			},
@@ -338,10 +904,12 @@ func TestFetchMatches(t *testing.T) {
 		t.Run(tc.testName, func(t *testing.T) {
 			// t.Parallel()
 
-			gotData, gotErr := tc.mockFetchData.FetchMatches(tc.inputData)
+			gotData, gotErr := tc.mockFetchData.FetchMatches(context.Background(), tc.inputData, tc.inputStates)
 			assert.Equal(t, tc.wantData.GameName, gotData.GameName)
 			assert.Equal(t, tc.wantData.TournamentId, gotData.TournamentId)
 			assert.ElementsMatch(t, tc.wantData.MatchList, gotData.MatchList)
+			assert.ElementsMatch(t, tc.wantData.UnknownParticipantIds, gotData.UnknownParticipantIds)
+			assert.Equal(t, tc.wantData.ResolvedParticipants, gotData.ResolvedParticipants)
 			if tc.wantErr != nil {
 				assert.EqualError(t, gotErr, tc.wantErr.Error())
 			} else {
@@ -351,6 +919,272 @@ func TestFetchMatches(t *testing.T) {
 	}
 }
 
+func TestAPIError(t *testing.T) {
+	t.Run("decodes status, endpoint and error details", func(t *testing.T) {
+		// Given
+		mockFetchData := New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second)
+		// When
+		_, gotErr := mockFetchData.FetchMatches(context.Background(), models.TournamentParticipants{TournamentID: "4040"}, models.DefaultMatchStates)
+		// Then
+		var apiErr *APIError
+		require.True(t, errors.As(gotErr, &apiErr))
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, "/tournaments/4040/matches.json", apiErr.Endpoint)
+		assert.Equal(t, "4040", apiErr.TournamentID)
+		require.Len(t, apiErr.Details, 1)
+		assert.Equal(t, "Tournament not found", apiErr.Details[0].Detail)
+		assert.True(t, errors.Is(gotErr, ErrResponseNotOK))
+		assert.EqualError(t, gotErr, "response not ok. Not Found from /tournaments/4040/matches.json: Tournament not found")
+	})
+
+	t.Run("keeps retry after and tolerates an empty body", func(t *testing.T) {
+		// Given
+		mockFetchData := New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+		// When
+		_, gotErr := mockFetchData.FetchMatches(context.Background(), models.TournamentParticipants{TournamentID: "7777"}, models.DefaultMatchStates)
+		// Then
+		var apiErr *APIError
+		require.True(t, errors.As(gotErr, &apiErr))
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+		assert.Equal(t, time.Second, apiErr.RetryAfter)
+		assert.Empty(t, apiErr.Details)
+	})
+}
+
+func TestNextPage(t *testing.T) {
+	nextLink := func(page int) models.Links {
+		return models.Links{Next: "https://api.challonge.com/v2.1/tournaments.json?page=" + strconv.Itoa(page) + "&per_page=25"}
+	}
+	tt := []struct {
+		testName     string
+		links        models.Links
+		meta         models.Meta
+		pageNumber   int
+		perPage      int
+		pageSize     int
+		received     int
+		wantPage     int
+		wantContinue bool
+	}{
+		{testName: "follows next link", links: nextLink(2), meta: models.Meta{Count: 30}, pageNumber: 1, perPage: 25, pageSize: 25, received: 25, wantPage: 2, wantContinue: true},
+		{testName: "follows next link without count", links: nextLink(2), pageNumber: 1, perPage: 25, pageSize: 25, received: 25, wantPage: 2, wantContinue: true},
+		{testName: "stops once count is reached", links: nextLink(3), meta: models.Meta{Count: 30}, pageNumber: 2, perPage: 5, pageSize: 5, received: 30, wantPage: 2, wantContinue: false},
+		{testName: "stops without next link", links: models.Links{}, pageNumber: 1, perPage: 25, pageSize: 25, received: 25, wantPage: 1, wantContinue: false},
+		{testName: "stops when next link does not advance", links: nextLink(2), pageNumber: 2, perPage: 25, pageSize: 25, received: 50, wantPage: 2, wantContinue: false},
+		{testName: "stops on a page shorter than asked for", links: nextLink(2), meta: models.Meta{Count: 17}, pageNumber: 1, perPage: 50, pageSize: 3, received: 3, wantPage: 1, wantContinue: false},
+		{testName: "stops on empty page", links: nextLink(2), meta: models.Meta{Count: 30}, pageNumber: 1, perPage: 25, pageSize: 0, received: 0, wantPage: 1, wantContinue: false},
+		{testName: "stops on malformed next link", links: models.Links{Next: "%zz"}, pageNumber: 1, perPage: 25, pageSize: 25, received: 25, wantPage: 1, wantContinue: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testName, func(t *testing.T) {
+			gotPage, gotContinue := nextPage(tc.links, tc.meta, tc.pageNumber, tc.perPage, tc.pageSize, tc.received)
+			assert.Equal(t, tc.wantPage, gotPage)
+			assert.Equal(t, tc.wantContinue, gotContinue)
+		})
+	}
+}
+
+func TestFetchMatchesPageRequests(t *testing.T) {
+	t.Run("It should stop after a page shorter than per_page", func(t *testing.T) {
+		// Given
+		// the captured response holds 3 open matches, counts all 17 of the tournament and links a next page
+		pageRequestCount.Store(0)
+		fetchData := New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second)
+		participants := models.TournamentParticipants{
+			GameName:     "test",
+			TournamentID: "1111",
+			Participant:  map[string]string{"1": "testName1", "2": "testName2", "3": "testName3", "4": "testName4"},
+		}
+
+		// When
+		gotData, gotErr := fetchData.FetchMatches(context.Background(), participants, []models.MatchState{models.MatchStateOpen})
+
+		// Then
+		require.NoError(t, gotErr)
+		assert.Len(t, gotData.MatchList, 3)
+		assert.Equal(t, int32(1), pageRequestCount.Load())
+	})
+}
+
+func TestFetchMatchesContext(t *testing.T) {
+	inputData := models.TournamentParticipants{
+		GameName:     "test",
+		TournamentID: "9999",
+		Participant:  map[string]string{},
+	}
+
+	t.Run("cancelled context stops the request", func(t *testing.T) {
+		// Given
+		mockFetchData := New(server.URL, MOCK_API_KEY, http.DefaultClient, 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		// When
+		_, gotErr := mockFetchData.FetchMatches(ctx, inputData, models.DefaultMatchStates)
+		// Then
+		assert.True(t, errors.Is(gotErr, context.Canceled))
+	})
+
+	t.Run("caller deadline bounds the request", func(t *testing.T) {
+		// Given
+		mockFetchData := New(server.URL, MOCK_API_KEY, http.DefaultClient, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		// When
+		_, gotErr := mockFetchData.FetchMatches(ctx, inputData, models.DefaultMatchStates)
+		// Then
+		assert.True(t, errors.Is(gotErr, context.DeadlineExceeded))
+	})
+}
+
+func TestFetchMatchesRetry(t *testing.T) {
+	retryPolicy := WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+	tt := []struct {
+		testName     string
+		tournamentId string
+		wantAttempts int32
+		wantErr      *APIError
+	}{
+		{
+			testName:     "bad gateway is retried until ok",
+			tournamentId: "5555",
+			wantAttempts: 3,
+			wantErr:      nil,
+		},
+		{
+			testName:     "too many requests honors retry after",
+			tournamentId: "6666",
+			wantAttempts: 3,
+			wantErr:      nil,
+		},
+		{
+			testName:     "gives up after max attempts",
+			tournamentId: "7778",
+			wantAttempts: 3,
+			wantErr:      &APIError{StatusCode: http.StatusServiceUnavailable, Endpoint: "/tournaments/7778/matches.json", TournamentID: "7778"},
+		},
+		{
+			testName:     "retry after longer than max delay is not retried",
+			tournamentId: "7777",
+			wantAttempts: 1,
+			wantErr:      &APIError{StatusCode: http.StatusServiceUnavailable, Endpoint: "/tournaments/7777/matches.json", TournamentID: "7777", RetryAfter: time.Second},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Given
+			flakyRequestCount.Store(0)
+			mockFetchData := New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second, retryPolicy)
+			// When
+			gotData, gotErr := mockFetchData.FetchMatches(context.Background(), models.TournamentParticipants{
+				GameName:     "test",
+				TournamentID: tc.tournamentId,
+				Participant:  map[string]string{"1": "testName1", "2": "testName2"},
+			}, models.DefaultMatchStates)
+			// Then
+			assert.Equal(t, tc.wantAttempts, flakyRequestCount.Load())
+			if tc.wantErr != nil {
+				var apiErr *APIError
+				require.True(t, errors.As(gotErr, &apiErr))
+				assert.Equal(t, tc.wantErr, apiErr)
+			} else {
+				assert.NoError(t, gotErr)
+				assert.NotEmpty(t, gotData.MatchList)
+			}
+		})
+	}
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		// Given
+		flakyRequestCount.Store(0)
+		mockFetchData := New(server.URL, "bad api key", http.DefaultClient, 5*time.Second, retryPolicy)
+		// When
+		_, gotErr := mockFetchData.FetchMatches(context.Background(), models.TournamentParticipants{TournamentID: "5555"}, models.DefaultMatchStates)
+		// Then
+		assert.EqualError(t, gotErr, (&APIError{StatusCode: http.StatusUnauthorized, Endpoint: "/tournaments/5555/matches.json", TournamentID: "5555"}).Error())
+		assert.Equal(t, int32(1), flakyRequestCount.Load())
+	})
+}
+
+func TestFetchMatchesThrottle(t *testing.T) {
+	inputData := models.TournamentParticipants{
+		GameName:     "test",
+		TournamentID: "8888",
+		Participant:  map[string]string{},
+	}
+
+	t.Run("concurrent requests are capped", func(t *testing.T) {
+		// Given
+		slowMaxInFlight.Store(0)
+		mockFetchData := New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second, WithRateLimit(0, 0, 2))
+		// When
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := mockFetchData.FetchMatches(context.Background(), inputData, models.DefaultMatchStates)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		// Then
+		assert.Equal(t, int32(2), slowMaxInFlight.Load())
+	})
+
+	t.Run("requests are rate limited", func(t *testing.T) {
+		// Given
+		mockFetchData := New(server.URL, MOCK_API_KEY, http.DefaultClient, 5*time.Second, WithRateLimit(50, 1, 0))
+		start := time.Now()
+		// When
+		for i := 0; i < 6; i++ {
+			_, err := mockFetchData.FetchMatches(context.Background(), inputData, models.DefaultMatchStates)
+			require.NoError(t, err)
+		}
+		// Then
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("waiting for a slot respects cancellation", func(t *testing.T) {
+		// Given
+		mockFetchData := New(server.URL, MOCK_API_KEY, http.DefaultClient, 0, WithRateLimit(0, 0, 1))
+		release, err := mockFetchData.throttle.acquire(context.Background())
+		require.NoError(t, err)
+		defer release()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		// When
+		_, gotErr := mockFetchData.FetchMatches(ctx, inputData, models.DefaultMatchStates)
+		// Then
+		assert.True(t, errors.Is(gotErr, context.DeadlineExceeded))
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 11, 25, 14, 0, 0, 0, time.UTC)
+	tt := []struct {
+		testName  string
+		value     string
+		wantDelay time.Duration
+		wantOk    bool
+	}{
+		{testName: "seconds", value: "3", wantDelay: 3 * time.Second, wantOk: true},
+		{testName: "http date", value: now.Add(10 * time.Second).Format(http.TimeFormat), wantDelay: 10 * time.Second, wantOk: true},
+		{testName: "http date in the past", value: now.Add(-10 * time.Second).Format(http.TimeFormat), wantDelay: 0, wantOk: true},
+		{testName: "empty", value: "", wantDelay: 0, wantOk: false},
+		{testName: "garbage", value: "soon", wantDelay: 0, wantOk: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testName, func(t *testing.T) {
+			gotDelay, gotOk := parseRetryAfter(tc.value, now)
+			assert.Equal(t, tc.wantDelay, gotDelay)
+			assert.Equal(t, tc.wantOk, gotOk)
+		})
+	}
+}
+
 // helper functions
 func testApiKeyAuth(apiKey string) bool {
 	return apiKey == MOCK_API_KEY
@@ -371,6 +1205,17 @@ func readJsonFile(filename string) ([]byte, error) {
 
 }
 
+// mockTournamentSummary is the summary of the tournaments in the multi tournament mock responses
+func mockTournamentSummary(id, gameName string) models.TournamentSummary {
+	return models.TournamentSummary{
+		Id:             id,
+		Name:           "testName",
+		GameName:       gameName,
+		TournamentType: "tournament_type",
+		State:          "state",
+	}
+}
+
 // mock endpoints
 func mockFetchTournamentEndpoint(w http.ResponseWriter, r *http.Request) {
 	emptyReturn, _ := readJsonFile("./mock-api-responses/mock-tournament-response-empty.json")
@@ -429,6 +1274,17 @@ func mockFetchTournamentEndpoint(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// only pending tournaments created before the end of the window
+	if date == "2023-07-19" {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page > 1 || r.URL.Query().Get("state") != "pending" || r.URL.Query().Get("created_before") != "2023-07-21" {
+			w.Write(emptyReturn)
+			return
+		}
+		byteValue, _ := readJsonFile("./mock-api-responses/mock-tournament-response.json")
+		w.Write(byteValue)
+	}
+
 }
 
 func mockFetchParticipantEndpoint(w http.ResponseWriter, r *http.Request) {
@@ -456,6 +1312,15 @@ func mockFetchParticipantEndpoint(w http.ResponseWriter, r *http.Request) {
 		byteValue, _ := readJsonFile("./mock-api-responses/mock-participant-response.json")
 		w.Write(byteValue)
 	}
+	if strings.Contains(r.URL.Path, "7890") {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page > 1 {
+			w.Write(emptyReturn)
+			return
+		}
+		byteValue, _ := readJsonFile("./mock-api-responses/mock-participant-username-response.json")
+		w.Write(byteValue)
+	}
 	if strings.Contains(r.URL.Path, "112358") {
 		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 		if page >= 3 {
@@ -495,4 +1360,79 @@ func mockFetchMatchesEndpoint(w http.ResponseWriter, r *http.Request) {
 		byteValue, _ := readJsonFile("./mock-api-responses/mock-matches-response.json")
 		w.Write(byteValue)
 	}
+	if strings.Contains(r.URL.Path, "4234") {
+		byteValue, _ := readJsonFile("./mock-api-responses/mock-matches-pending-response.json")
+		state := r.URL.Query().Get("state")
+		if state == "" {
+			w.Write(byteValue)
+			return
+		}
+		var matches models.Matches
+		json.Unmarshal(byteValue, &matches)
+		matches.Data = slices.DeleteFunc(matches.Data, func(match models.ChallongeMatch) bool {
+			return string(match.Attributes.State) != state
+		})
+		matches.Meta.Count = len(matches.Data)
+		json.NewEncoder(w).Encode(matches)
+	}
+	if strings.Contains(r.URL.Path, "3234") {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page >= 3 {
+			w.Write(emptyReturn)
+		}
+		if page == 1 {
+			byteValue, _ := readJsonFile("./mock-api-responses/mock-matches-multi-response.json")
+			w.Write(byteValue)
+		}
+		if page == 2 {
+			byteValue, _ := readJsonFile("./mock-api-responses/mock-matches-response-page2.json")
+			w.Write(byteValue)
+		}
+	}
+}
+
+// mockTime parses a timestamp as it appears in the mock responses
+func mockTime(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+// mockFlakyEndpoint responds with failStatus for the first two requests and with matches afterwards
+func mockFlakyEndpoint(w http.ResponseWriter, r *http.Request, failStatus int) {
+	count := flakyRequestCount.Add(1)
+
+	apiKey := r.Header.Get("Authorization")
+	if !testApiKeyAuth(apiKey) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if count <= 2 {
+		w.WriteHeader(failStatus)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	byteValue, _ := readJsonFile("./mock-api-responses/mock-matches-response.json")
+	w.Write(byteValue)
+}
+
+// mockSlowEndpoint holds each request briefly and tracks the peak number served concurrently
+func mockSlowEndpoint(w http.ResponseWriter, r *http.Request) {
+	inFlight := slowInFlight.Add(1)
+	defer slowInFlight.Add(-1)
+	for {
+		peak := slowMaxInFlight.Load()
+		if inFlight <= peak || slowMaxInFlight.CompareAndSwap(peak, inFlight) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	emptyReturn, _ := readJsonFile("./mock-api-responses/mock-tournament-response-empty.json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(emptyReturn)
 }
@@ -0,0 +1,101 @@
+package challongebracketmatches
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type (
+	// APIError is returned when Challonge responds with a non 200 status. It unwraps to ErrResponseNotOK
+	APIError struct {
+		StatusCode int
+		// Endpoint is the path that was requested, without query params
+		Endpoint string
+		// TournamentID is empty for requests that are not about a single tournament
+		TournamentID string
+		// RetryAfter is how long Challonge asked us to wait, zero if it did not say
+		RetryAfter time.Duration
+		Details    []APIErrorDetail
+	}
+
+	// APIErrorDetail is a single entry of the JSON:API errors payload
+	APIErrorDetail struct {
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+		Source struct {
+			Pointer string `json:"pointer"`
+		} `json:"source"`
+	}
+)
+
+// maximum amount of an error body that is read looking for JSON:API errors
+const maxErrorBodySize = 64 << 10
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%v. %s from %s", ErrResponseNotOK, http.StatusText(e.StatusCode), e.Endpoint)
+	if len(e.Details) == 0 {
+		return msg
+	}
+
+	details := make([]string, 0, len(e.Details))
+	for _, detail := range e.Details {
+		switch {
+		case detail.Detail != "":
+			details = append(details, detail.Detail)
+		case detail.Title != "":
+			details = append(details, detail.Title)
+		}
+	}
+	if len(details) == 0 {
+		return msg
+	}
+	return msg + ": " + strings.Join(details, "; ")
+}
+
+func (e *APIError) Unwrap() error {
+	return ErrResponseNotOK
+}
+
+// newAPIError builds an APIError from a non 200 response and closes its body
+func newAPIError(res *http.Response, tournamentId string) *APIError {
+	defer res.Body.Close()
+
+	apiErr := &APIError{
+		StatusCode:   res.StatusCode,
+		TournamentID: tournamentId,
+	}
+	if res.Request != nil {
+		apiErr.Endpoint = res.Request.URL.Path
+	}
+	if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+		apiErr.RetryAfter = retryAfter
+	}
+
+	var payload struct {
+		Errors []APIErrorDetail `json:"errors"`
+	}
+	// the body is best effort, Challonge does not always send one
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxErrorBodySize)).Decode(&payload); err == nil {
+		apiErr.Details = payload.Errors
+	}
+	return apiErr
+}
+
+// TournamentError is a failed fetch for a single tournament when the data of other tournaments is still usable
+type TournamentError struct {
+	TournamentID string
+	GameName     string
+	Err          error
+}
+
+func (e TournamentError) Error() string {
+	return fmt.Sprintf("tournament %s (%s): %v", e.TournamentID, e.GameName, e.Err)
+}
+
+func (e TournamentError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,140 @@
+package challongebracketmatches
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type (
+	// RetryPolicy controls how failed requests to Challonge are retried
+	RetryPolicy struct {
+		// MaxAttempts is the total number of attempts, including the first one. Values below 1 are treated as 1
+		MaxAttempts int
+		// BaseDelay is the backoff before the first retry, doubled on every further attempt
+		BaseDelay time.Duration
+		// MaxDelay caps the backoff. A Retry-After sent by Challonge that is longer stops the retries so the
+		// response is returned with it
+		MaxDelay time.Duration
+	}
+
+	Option func(*customClient)
+)
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   250 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// WithRetryPolicy overrides DefaultRetryPolicy for the client
+func WithRetryPolicy(retryPolicy RetryPolicy) Option {
+	return func(c *customClient) {
+		c.retryPolicy = retryPolicy
+	}
+}
+
+// do sends req, retrying idempotent requests on network errors and retryable status codes
+func (c *customClient) do(req *http.Request) (*http.Response, error) {
+	maxAttempts := c.retryPolicy.MaxAttempts
+	if maxAttempts < 1 || !isIdempotent(req.Method) {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		release, err := c.throttle.acquire(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		res, err := c.client.Do(req.Clone(req.Context()))
+		if err != nil {
+			release()
+		} else {
+			res.Body = &releaseOnClose{ReadCloser: res.Body, release: release}
+		}
+		if attempt >= maxAttempts || !shouldRetry(req.Context(), res, err) {
+			if attempt > 1 {
+				slog.Info("challonge request finished after retries", "url", req.URL.Path, "attempts", attempt)
+			}
+			return res, err
+		}
+
+		delay := c.retryPolicy.backoff(attempt)
+		if err != nil {
+			slog.Warn("retrying challonge request", "url", req.URL.Path, "attempt", attempt, "delay", delay, "error", err)
+		} else {
+			if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+				// waiting less than Challonge asked would only trip its limiter again, the caller is told to wait instead
+				if c.retryPolicy.MaxDelay > 0 && retryAfter > c.retryPolicy.MaxDelay {
+					slog.Warn("not retrying challonge request, retry after exceeds max delay", "url", req.URL.Path, "attempt", attempt, "retry_after", retryAfter, "status", res.StatusCode)
+					return res, nil
+				}
+				delay = retryAfter
+			}
+			slog.Warn("retrying challonge request", "url", req.URL.Path, "attempt", attempt, "delay", delay, "status", res.StatusCode)
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the exponential backoff with full jitter for the given attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func shouldRetry(ctx context.Context, res *http.Response, err error) bool {
+	if err != nil {
+		// the caller gave up, retrying would be pointless
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package challongebracketmatches
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// throttle is shared by every request a client makes, so handlers and cache refreshes are limited together
+type throttle struct {
+	limiter *rate.Limiter
+	slots   chan struct{}
+	queued  atomic.Int64
+}
+
+// releaseOnClose hands back a throttle slot once the response body has been closed
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// WithRateLimit limits the client to requestsPerSecond with the given burst, and to at most maxConcurrent
+// requests in flight at once. A non-positive requestsPerSecond or maxConcurrent disables that limit
+func WithRateLimit(requestsPerSecond float64, burst, maxConcurrent int) Option {
+	return func(c *customClient) {
+		t := &throttle{}
+		if requestsPerSecond > 0 {
+			t.limiter = rate.NewLimiter(rate.Limit(requestsPerSecond), max(burst, 1))
+		}
+		if maxConcurrent > 0 {
+			t.slots = make(chan struct{}, maxConcurrent)
+		}
+		c.throttle = t
+	}
+}
+
+// acquire blocks until the request may be sent. The returned func must be called once the request is done
+func (t *throttle) acquire(ctx context.Context) (func(), error) {
+	if t == nil {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	queueDepth := t.queued.Add(1)
+	defer t.queued.Add(-1)
+
+	release := func() {}
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+			release = func() { <-t.slots }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if t.limiter != nil {
+		if err := t.limiter.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	if waited := time.Since(start); waited >= time.Millisecond {
+		slog.Info("challonge request throttled", "queue_depth", queueDepth, "waited", waited)
+	}
+	return release, nil
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
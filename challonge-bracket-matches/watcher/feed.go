@@ -0,0 +1,334 @@
+package watcher
+
+import (
+	"cmp"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/models"
+)
+
+type (
+	// feed holds what the last poll of a filter found and the changes it made, for every subscriber of the filter
+	feed struct {
+		filter models.TournamentFilter
+		// pollMu keeps a single poll of the filter running at a time
+		pollMu sync.Mutex
+
+		mu     sync.Mutex
+		polled time.Time
+		// states are the ones the last poll fetched, completePolled is when complete matches last were
+		states         []models.MatchState
+		completePolled time.Time
+		participants   []models.TournamentParticipants
+		tournaments    map[string]models.TournamentMatches
+		errors         []challongebracketmatches.TournamentError
+		// changes are the latest changes, every change numbered after base is among them. last is the number of the
+		// latest change, base when there is none
+		changes     []change
+		base        uint64
+		last        uint64
+		subscribers map[*subscriber]struct{}
+		idleSince   time.Time
+		stopped     bool
+		// joining counts the subscribers on their way in, it is guarded by the watcher's mu
+		joining int
+	}
+
+	// change is a match that was added, changed or removed between two polls. before is nil for a match that was not
+	// there and after for a match that is gone
+	change struct {
+		seq          uint64
+		gameName     string
+		tournamentId string
+		before       *models.Match
+		after        *models.Match
+	}
+
+	subscriber struct {
+		view   View
+		events chan models.MatchEvent
+	}
+
+	// View is what a subscriber is shown of a filter: the matches in States, every state when it is empty, of the
+	// tournaments Select reports. Select is called from the watcher's goroutines and every tournament is shown when
+	// it is nil
+	View struct {
+		States []models.MatchState
+		Select func(gameName, tournamentId string) bool
+	}
+
+	// Snapshot is every match a view was shown as of the event EventId
+	Snapshot struct {
+		EventId      string
+		Participants []models.TournamentParticipants
+		Tournaments  []models.TournamentMatches
+		Errors       []challongebracketmatches.TournamentError
+	}
+
+	// Subscription receives the events of the filter it was made for. It comes with either the Snapshot the events
+	// follow, or the events Missed since the event it resumed from
+	Subscription struct {
+		Snapshot *Snapshot
+		Missed   []models.MatchEvent
+		// Events is closed when the subscriber falls more than the subscriber buffer behind, or the watcher stops
+		Events <-chan models.MatchEvent
+
+		watcher *Watcher
+		feed    *feed
+		sub     *subscriber
+	}
+)
+
+func newFeed(filter models.TournamentFilter, seq uint64) *feed {
+	return &feed{
+		filter:      filter,
+		tournaments: map[string]models.TournamentMatches{},
+		base:        seq,
+		last:        seq,
+		subscribers: map[*subscriber]struct{}{},
+		idleSince:   time.Now(),
+	}
+}
+
+// Close stops the subscription. Events is closed once it returns
+func (s *Subscription) Close() {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+
+	s.feed.remove(s.sub)
+}
+
+// Reselect changes the tournaments the subscription is shown to the ones selectFn reports and returns a snapshot of
+// the ones it was not shown before. Events already in Events may still be for tournaments it is no longer shown
+func (s *Subscription) Reselect(selectFn func(gameName, tournamentId string) bool) Snapshot {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+
+	previous := s.sub.view
+	s.sub.view.Select = selectFn
+	current := s.sub.view
+	snapshot := s.feed.snapshot(View{
+		States: current.States,
+		Select: func(gameName, tournamentId string) bool {
+			return current.selects(gameName, tournamentId) && !previous.selects(gameName, tournamentId)
+		},
+	})
+	snapshot.EventId = s.watcher.eventId(s.feed.last)
+	return snapshot
+}
+
+// update replaces what f holds with the results of a poll of states and returns the changes since the previous poll,
+// none on the first one. Tournaments that failed keep their previous matches. A match that wasn't found is kept too
+// unless both its state and complete were fetched, so an open match is only reported gone once the complete matches
+// show it didn't complete. It must be called with f.mu held
+func (f *feed) update(participants []models.TournamentParticipants, matches []models.TournamentMatches, states []models.MatchState, fetchErrors []challongebracketmatches.TournamentError) []change {
+	settled := func(state models.MatchState) bool {
+		return slices.Contains(states, state) && slices.Contains(states, models.MatchStateComplete)
+	}
+	tournaments := map[string]models.TournamentMatches{}
+	for _, tournament := range matches {
+		for _, match := range f.tournaments[tournament.TournamentId].MatchList {
+			if !settled(match.State) && !slices.ContainsFunc(tournament.MatchList, func(fetched models.Match) bool { return fetched.Id == match.Id }) {
+				tournament.MatchList = append(tournament.MatchList, match)
+			}
+		}
+		slices.SortStableFunc(tournament.MatchList, func(a, b models.Match) int {
+			return cmp.Compare(a.SuggestedPlayOrder, b.SuggestedPlayOrder)
+		})
+		tournaments[tournament.TournamentId] = tournament
+	}
+	for _, fetchErr := range fetchErrors {
+		if previous, ok := f.tournaments[fetchErr.TournamentID]; ok {
+			tournaments[fetchErr.TournamentID] = previous
+		}
+	}
+
+	var changes []change
+	if !f.polled.IsZero() {
+		changes = diffTournaments(f.tournaments, tournaments)
+	}
+	f.polled = time.Now()
+	f.states = states
+	if slices.Contains(states, models.MatchStateComplete) {
+		f.completePolled = f.polled
+	}
+	f.participants = participants
+	f.tournaments = tournaments
+	f.errors = fetchErrors
+	return changes
+}
+
+// publish records change and sends it to every subscriber shown it. Subscribers too far behind to take it are dropped.
+// It must be called with f.mu held
+func (f *feed) publish(change change, eventId string, history int, logger *slog.Logger) {
+	f.changes = append(f.changes, change)
+	f.last = change.seq
+	if len(f.changes) > history {
+		dropped := len(f.changes) - history
+		f.base = f.changes[dropped-1].seq
+		f.changes = slices.Clone(f.changes[dropped:])
+	}
+
+	for sub := range f.subscribers {
+		event, ok := sub.view.event(change, eventId)
+		if !ok {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			logger.Warn("Match watcher subscriber fell behind, dropping it", "filter", f.filter.Key())
+			f.remove(sub)
+		}
+	}
+}
+
+// remove closes the events of sub if it is still subscribed. It must be called with f.mu held
+func (f *feed) remove(sub *subscriber) {
+	if _, ok := f.subscribers[sub]; !ok {
+		return
+	}
+	delete(f.subscribers, sub)
+	close(sub.events)
+	if len(f.subscribers) == 0 {
+		f.idleSince = time.Now()
+	}
+}
+
+// snapshot returns the matches view is shown, ordered by game like the matches endpoint. It must be called with f.mu held
+func (f *feed) snapshot(view View) Snapshot {
+	snapshot := Snapshot{
+		Participants: []models.TournamentParticipants{},
+		Tournaments:  []models.TournamentMatches{},
+		Errors:       []challongebracketmatches.TournamentError{},
+	}
+	for _, participants := range f.participants {
+		if view.selects(participants.GameName, participants.TournamentID) {
+			snapshot.Participants = append(snapshot.Participants, participants)
+		}
+	}
+	for _, tournament := range f.tournaments {
+		if !view.selects(tournament.GameName, tournament.TournamentId) {
+			continue
+		}
+		matchList := []models.Match{}
+		for _, match := range tournament.MatchList {
+			if view.shows(match.State) {
+				matchList = append(matchList, match)
+			}
+		}
+		tournament.MatchList = matchList
+		snapshot.Tournaments = append(snapshot.Tournaments, tournament)
+	}
+	slices.SortFunc(snapshot.Tournaments, func(a, b models.TournamentMatches) int {
+		return cmp.Or(strings.Compare(a.GameName, b.GameName), strings.Compare(a.TournamentId, b.TournamentId))
+	})
+	for _, fetchErr := range f.errors {
+		if view.selects(fetchErr.GameName, fetchErr.TournamentID) {
+			snapshot.Errors = append(snapshot.Errors, fetchErr)
+		}
+	}
+	return snapshot
+}
+
+// diffTournaments returns the matches added, changed or removed between two polls, ordered by tournament and then by
+// the order of the new match lists, removed matches last
+func diffTournaments(previous, current map[string]models.TournamentMatches) []change {
+	var changes []change
+	var tournamentIds, removedIds []string
+	for tournamentId := range current {
+		tournamentIds = append(tournamentIds, tournamentId)
+	}
+	for tournamentId := range previous {
+		if _, ok := current[tournamentId]; !ok {
+			removedIds = append(removedIds, tournamentId)
+		}
+	}
+	slices.Sort(tournamentIds)
+	slices.Sort(removedIds)
+	tournamentIds = append(tournamentIds, removedIds...)
+
+	for _, tournamentId := range tournamentIds {
+		before, after := previous[tournamentId], current[tournamentId]
+		gameName := cmp.Or(after.GameName, before.GameName)
+		beforeMatches := map[string]models.Match{}
+		for _, match := range before.MatchList {
+			beforeMatches[match.Id] = match
+		}
+		for _, match := range after.MatchList {
+			beforeMatch, ok := beforeMatches[match.Id]
+			delete(beforeMatches, match.Id)
+			if ok && reflect.DeepEqual(beforeMatch, match) {
+				continue
+			}
+			change := change{gameName: gameName, tournamentId: tournamentId, after: &match}
+			if ok {
+				change.before = &beforeMatch
+			}
+			changes = append(changes, change)
+		}
+		for _, match := range before.MatchList {
+			if _, ok := beforeMatches[match.Id]; ok {
+				changes = append(changes, change{gameName: gameName, tournamentId: tournamentId, before: &match})
+			}
+		}
+	}
+	return changes
+}
+
+// event returns the event change makes for the view, false when the view is not shown the match before nor after it
+func (v View) event(change change, eventId string) (models.MatchEvent, bool) {
+	if !v.selects(change.gameName, change.tournamentId) {
+		return models.MatchEvent{}, false
+	}
+	shownBefore := change.before != nil && v.shows(change.before.State)
+	shownAfter := change.after != nil && v.shows(change.after.State)
+	event := models.MatchEvent{
+		Id:           eventId,
+		GameName:     change.gameName,
+		TournamentId: change.tournamentId,
+	}
+	switch {
+	case !shownBefore && !shownAfter:
+		return models.MatchEvent{}, false
+	case !shownBefore:
+		event.Type, event.Match = models.MatchEventAdded, *change.after
+	case change.after != nil && change.after.State == models.MatchStateComplete && change.before.State != models.MatchStateComplete:
+		event.Type, event.Match = models.MatchEventCompleted, *change.after
+	case !shownAfter:
+		event.Type, event.Match = models.MatchEventRemoved, *change.before
+	case change.after.Underway && !change.before.Underway:
+		event.Type, event.Match = models.MatchEventUnderway, *change.after
+	case change.after.Station != "" && change.after.Station != change.before.Station:
+		event.Type, event.Match = models.MatchEventStationAssigned, *change.after
+	default:
+		event.Type, event.Match = models.MatchEventUpdated, *change.after
+	}
+	return event, true
+}
+
+// pollStates are the states polled for views every interval: the open and pending ones they are shown, both for a view
+// of every state. Complete matches are left to the slower complete polls
+func pollStates(views ...View) []models.MatchState {
+	var states []models.MatchState
+	for _, state := range []models.MatchState{models.MatchStateOpen, models.MatchStatePending} {
+		if slices.ContainsFunc(views, func(view View) bool { return view.shows(state) }) {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+func (v View) selects(gameName, tournamentId string) bool {
+	return v.Select == nil || v.Select(gameName, tournamentId)
+}
+
+func (v View) shows(state models.MatchState) bool {
+	return len(v.States) == 0 || slices.Contains(v.States, state)
+}
@@ -0,0 +1,346 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/models"
+)
+
+const (
+	// DefaultHistory is how many changes a feed keeps for subscribers resuming from an earlier event
+	DefaultHistory = 512
+	// DefaultSubscriberBuffer is how many events a subscriber can fall behind before it is dropped
+	DefaultSubscriberBuffer = 64
+	// DefaultIdleTimeout is how long a feed nobody subscribes to is kept so reconnecting clients can still resume
+	DefaultIdleTimeout = 5 * time.Minute
+	// DefaultCompleteInterval is how often the complete matches are polled. They only grow over a tournament and take
+	// the most pages, so an open match that is gone is held until they show whether it completed
+	DefaultCompleteInterval = time.Minute
+)
+
+// ErrStopped is returned when subscribing after the watcher stopped
+var ErrStopped = errors.New("match watcher stopped")
+
+type (
+	// Watcher polls the matches of every filter clients are subscribed to from a single loop and turns what changed
+	// between two polls into events, so clients waiting on changes share one poll instead of each polling Challonge
+	Watcher struct {
+		cache            *cache.Cache
+		fetchData        challongebracketmatches.FetchData
+		interval         time.Duration
+		completeInterval time.Duration
+		history          int
+		buffer           int
+		idleTimeout      time.Duration
+		logger           *slog.Logger
+		// epoch tells the event ids of this watcher apart from the ones handed out before a restart
+		epoch int64
+		seq   atomic.Uint64
+
+		mu      sync.Mutex
+		feeds   map[string]*feed
+		stopped bool
+	}
+
+	Option func(*Watcher)
+)
+
+// WithHistory overrides DefaultHistory
+func WithHistory(history int) Option {
+	return func(w *Watcher) {
+		w.history = history
+	}
+}
+
+// WithSubscriberBuffer overrides DefaultSubscriberBuffer
+func WithSubscriberBuffer(buffer int) Option {
+	return func(w *Watcher) {
+		w.buffer = buffer
+	}
+}
+
+// WithIdleTimeout overrides DefaultIdleTimeout
+func WithIdleTimeout(idleTimeout time.Duration) Option {
+	return func(w *Watcher) {
+		w.idleTimeout = idleTimeout
+	}
+}
+
+// WithCompleteInterval overrides DefaultCompleteInterval
+func WithCompleteInterval(completeInterval time.Duration) Option {
+	return func(w *Watcher) {
+		w.completeInterval = completeInterval
+	}
+}
+
+// New creates a Watcher polling the filters subscribed to every interval through cache
+func New(cache *cache.Cache, fetchData challongebracketmatches.FetchData, interval time.Duration, logger *slog.Logger, opts ...Option) *Watcher {
+	w := &Watcher{
+		cache:            cache,
+		fetchData:        fetchData,
+		interval:         interval,
+		completeInterval: DefaultCompleteInterval,
+		history:          DefaultHistory,
+		buffer:           DefaultSubscriberBuffer,
+		idleTimeout:      DefaultIdleTimeout,
+		logger:           logger,
+		epoch:            time.Now().UnixMilli(),
+		feeds:            map[string]*feed{},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Run polls every filter with subscribers on every tick until ctx is done, then closes every subscription
+func (w *Watcher) Run(ctx context.Context) {
+	w.logger.Info("Match watcher started", "interval", w.interval)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.stop()
+			w.logger.Info("Match watcher stopped")
+			return
+		case <-ticker.C:
+			w.pollFeeds(ctx)
+		}
+	}
+}
+
+// Subscribe starts sending the changes to the matches of filter shown in view. The subscription resumes after
+// lastEventId when the feed still holds every change since, and comes with a snapshot of the matches otherwise.
+// A filter nobody was watching is polled before returning, an error is only returned if it has never been polled
+func (w *Watcher) Subscribe(ctx context.Context, filter models.TournamentFilter, lastEventId string, view View) (*Subscription, error) {
+	f, err := w.join(filter)
+	if err != nil {
+		return nil, err
+	}
+	defer w.leave(f)
+
+	if err := w.pollIfDue(ctx, f, view); err != nil {
+		f.mu.Lock()
+		polled := !f.polled.IsZero()
+		f.mu.Unlock()
+		if !polled {
+			return nil, err
+		}
+		w.logger.Error("Match watcher poll failed, resuming from the last poll", "filter", filter.Key(), "error", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.stopped {
+		return nil, ErrStopped
+	}
+	sub := &subscriber{view: view, events: make(chan models.MatchEvent, w.buffer)}
+	subscription := &Subscription{Events: sub.events, watcher: w, feed: f, sub: sub}
+	if seq, ok := w.parseEventId(lastEventId); ok && seq >= f.base && seq <= f.last {
+		subscription.Missed = []models.MatchEvent{}
+		for _, change := range f.changes {
+			if change.seq <= seq {
+				continue
+			}
+			if event, ok := view.event(change, w.eventId(change.seq)); ok {
+				subscription.Missed = append(subscription.Missed, event)
+			}
+		}
+	} else {
+		snapshot := f.snapshot(view)
+		snapshot.EventId = w.eventId(f.last)
+		subscription.Snapshot = &snapshot
+	}
+	f.subscribers[sub] = struct{}{}
+	return subscription, nil
+}
+
+// join returns the feed of filter, creating it when nobody is watching it, and keeps it from being dropped until
+// leave is called
+func (w *Watcher) join(filter models.TournamentFilter) (*feed, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopped {
+		return nil, ErrStopped
+	}
+	f, ok := w.feeds[filter.Key()]
+	if !ok {
+		f = newFeed(filter, w.seq.Load())
+		w.feeds[filter.Key()] = f
+	}
+	f.joining++
+	return f, nil
+}
+
+func (w *Watcher) leave(f *feed) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	f.joining--
+}
+
+// pollFeeds polls every feed with subscribers concurrently and drops the ones left idle for longer than the idle timeout
+func (w *Watcher) pollFeeds(ctx context.Context) {
+	var active []*feed
+	w.mu.Lock()
+	for key, f := range w.feeds {
+		f.mu.Lock()
+		switch {
+		case len(f.subscribers) > 0:
+			active = append(active, f)
+		case f.joining == 0 && time.Since(f.idleSince) > w.idleTimeout:
+			delete(w.feeds, key)
+		}
+		f.mu.Unlock()
+	}
+	w.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, f := range active {
+		wg.Add(1)
+		go func(f *feed) {
+			defer wg.Done()
+			if err := w.poll(ctx, f); err != nil {
+				w.logger.Error("Match watcher poll failed", "filter", f.filter.Key(), "error", err)
+			}
+		}(f)
+	}
+	wg.Wait()
+}
+
+// pollIfDue polls f for its subscribers and joining unless it was polled less than an interval ago in every state
+// joining is shown
+func (w *Watcher) pollIfDue(ctx context.Context, f *feed, joining View) error {
+	f.pollMu.Lock()
+	defer f.pollMu.Unlock()
+
+	f.mu.Lock()
+	due := time.Since(f.polled) >= w.interval || slices.ContainsFunc(pollStates(joining), func(state models.MatchState) bool {
+		return !slices.Contains(f.states, state)
+	})
+	f.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return w.pollLocked(ctx, f, joining)
+}
+
+func (w *Watcher) poll(ctx context.Context, f *feed) error {
+	f.pollMu.Lock()
+	defer f.pollMu.Unlock()
+
+	return w.pollLocked(ctx, f)
+}
+
+// pollLocked fetches the matches of f's filter in the states its subscribers and joining are shown, adding the complete
+// ones once the complete interval has passed, and publishes what changed since the last poll. It must be called with
+// f.pollMu held
+func (w *Watcher) pollLocked(ctx context.Context, f *feed, joining ...View) error {
+	if _, _, err := w.cache.EnsureData(ctx, f.filter, w.fetchData); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	views := joining
+	for sub := range f.subscribers {
+		views = append(views, sub.view)
+	}
+	states := pollStates(views...)
+	if time.Since(f.completePolled) >= w.completeInterval {
+		states = append([]models.MatchState{models.MatchStateComplete}, states...)
+	}
+	f.mu.Unlock()
+
+	participants := w.cache.GetData(f.filter, nil)
+	matches, fetchErrors := w.fetchMatches(ctx, participants, states)
+	fetchErrors = append(w.cache.GetErrors(f.filter, nil), fetchErrors...)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, change := range f.update(participants, matches, states, fetchErrors) {
+		change.seq = w.seq.Add(1)
+		f.publish(change, w.eventId(change.seq), w.history, w.logger)
+	}
+	return nil
+}
+
+// fetchMatches gets the matches of every tournament in each of states, a request per state since Challonge only filters
+// on a single one. A tournament that fails in any state is left out with the first error it returned
+func (w *Watcher) fetchMatches(ctx context.Context, participants []models.TournamentParticipants, states []models.MatchState) ([]models.TournamentMatches, []challongebracketmatches.TournamentError) {
+	tournaments := map[string]*models.TournamentMatches{}
+	for _, tournament := range participants {
+		tournaments[tournament.TournamentID] = &models.TournamentMatches{
+			GameName:     tournament.GameName,
+			TournamentId: tournament.TournamentID,
+			MatchList:    []models.Match{},
+		}
+	}
+
+	var fetchErrors []challongebracketmatches.TournamentError
+	for _, state := range states {
+		matches, stateErrors := w.cache.GetMatchesConcurrently(ctx, participants, []models.MatchState{state}, w.fetchData)
+		for _, fetchErr := range stateErrors {
+			if _, ok := tournaments[fetchErr.TournamentID]; ok {
+				delete(tournaments, fetchErr.TournamentID)
+				fetchErrors = append(fetchErrors, fetchErr)
+			}
+		}
+		for _, tournament := range matches {
+			if fetched, ok := tournaments[tournament.TournamentId]; ok {
+				fetched.MatchList = append(fetched.MatchList, tournament.MatchList...)
+			}
+		}
+	}
+
+	matches := []models.TournamentMatches{}
+	for _, tournament := range participants {
+		if fetched, ok := tournaments[tournament.TournamentID]; ok {
+			matches = append(matches, *fetched)
+		}
+	}
+	return matches, fetchErrors
+}
+
+// stop closes every subscription and keeps new ones from being made
+func (w *Watcher) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.stopped = true
+	for _, f := range w.feeds {
+		f.mu.Lock()
+		f.stopped = true
+		for sub := range f.subscribers {
+			f.remove(sub)
+		}
+		f.mu.Unlock()
+	}
+}
+
+// eventId is the id of the event sent for the change numbered seq
+func (w *Watcher) eventId(seq uint64) string {
+	return fmt.Sprintf("%d-%d", w.epoch, seq)
+}
+
+// parseEventId returns the change number of an event id handed out by this watcher
+func (w *Watcher) parseEventId(eventId string) (uint64, bool) {
+	var epoch int64
+	var seq uint64
+	if _, err := fmt.Sscanf(eventId, "%d-%d", &epoch, &seq); err != nil || epoch != w.epoch {
+		return 0, false
+	}
+	return seq, true
+}
@@ -0,0 +1,436 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFilter = models.DateFilter("2006-01-02")
+
+func TestSubscribe(t *testing.T) {
+	t.Run("It should poll a filter nobody watched and snapshot the matches the view is shown", func(t *testing.T) {
+		// Given
+		matchWatcher, _ := newTestWatcher(t)
+		// When
+		subscription, err := matchWatcher.Subscribe(context.Background(), testFilter, "", View{
+			States: []models.MatchState{models.MatchStateOpen},
+			Select: func(gameName, tournamentId string) bool { return gameName == "game1" },
+		})
+		// Then
+		require.NoError(t, err)
+		defer subscription.Close()
+		require.NotNil(t, subscription.Snapshot)
+		assert.Nil(t, subscription.Missed)
+		assert.Equal(t, []models.TournamentMatches{
+			{GameName: "game1", TournamentId: "1", MatchList: []models.Match{
+				{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain"},
+			}},
+		}, subscription.Snapshot.Tournaments)
+		assert.Len(t, subscription.Snapshot.Participants, 1)
+	})
+
+	t.Run("It should return the error of a first poll that failed", func(t *testing.T) {
+		// Given
+		matchWatcher, mockFetchData := newTestWatcher(t)
+		mockFetchData.failingTournaments = errors.New("tournaments failed")
+		// When
+		_, err := matchWatcher.Subscribe(context.Background(), testFilter, "", View{})
+		// Then
+		assert.EqualError(t, err, "tournaments failed")
+	})
+
+	t.Run("It should resume after the last event id it was given", func(t *testing.T) {
+		// Given
+		matchWatcher, mockFetchData := newTestWatcher(t)
+		first := subscribe(t, matchWatcher, "", View{})
+		mockFetchData.setMatch("1", models.Match{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", Underway: true})
+		matchWatcher.pollFeeds(context.Background())
+		mockFetchData.setMatch("1", models.Match{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", Underway: true, Station: "3"})
+		matchWatcher.pollFeeds(context.Background())
+		underway := <-first.Events
+		// When
+		resumed := subscribe(t, matchWatcher, underway.Id, View{})
+		// Then
+		assert.Nil(t, resumed.Snapshot)
+		require.Len(t, resumed.Missed, 1)
+		assert.Equal(t, models.MatchEventStationAssigned, resumed.Missed[0].Type)
+		assert.Equal(t, "3", resumed.Missed[0].Match.Station)
+	})
+
+	t.Run("It should send a snapshot when the changes since the last event id are no longer held", func(t *testing.T) {
+		// Given
+		matchWatcher, mockFetchData := newTestWatcher(t, WithHistory(1))
+		first := subscribe(t, matchWatcher, "", View{})
+		mockFetchData.setMatch("1", models.Match{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", Underway: true})
+		matchWatcher.pollFeeds(context.Background())
+		mockFetchData.setMatch("1", models.Match{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", Underway: true, Station: "3"})
+		mockFetchData.setMatch("2", models.Match{Id: "20", State: models.MatchStateOpen, Player1Name: "Armada", Player2Name: "Plup"})
+		matchWatcher.pollFeeds(context.Background())
+		underway := <-first.Events
+		// When
+		resumed := subscribe(t, matchWatcher, underway.Id, View{})
+		// Then
+		assert.Nil(t, resumed.Missed)
+		assert.NotNil(t, resumed.Snapshot)
+	})
+
+	t.Run("It should send a snapshot for an event id of another watcher", func(t *testing.T) {
+		// Given
+		matchWatcher, _ := newTestWatcher(t)
+		// When
+		subscription := subscribe(t, matchWatcher, "1-0", View{})
+		// Then
+		assert.NotNil(t, subscription.Snapshot)
+	})
+}
+
+func TestPollFeeds(t *testing.T) {
+	open := models.Match{Id: "11", State: models.MatchStateOpen, Player1Name: "Armada", Player2Name: "Plup"}
+	tt := []struct {
+		testName  string
+		view      View
+		change    func(mockFetchData *stubFetchData)
+		wantTypes []models.MatchEventType
+	}{
+		{
+			testName:  "It should send a match that opened as added to a view of open matches",
+			view:      View{States: []models.MatchState{models.MatchStateOpen}},
+			change:    func(mockFetchData *stubFetchData) { mockFetchData.setMatch("1", open) },
+			wantTypes: []models.MatchEventType{models.MatchEventAdded},
+		},
+		{
+			testName:  "It should send a match that opened as updated to a view of every state",
+			view:      View{},
+			change:    func(mockFetchData *stubFetchData) { mockFetchData.setMatch("1", open) },
+			wantTypes: []models.MatchEventType{models.MatchEventUpdated},
+		},
+		{
+			testName: "It should send the match that became underway and the one that got a station",
+			view:     View{},
+			change: func(mockFetchData *stubFetchData) {
+				mockFetchData.setMatch("1", models.Match{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", Underway: true})
+				mockFetchData.setMatch("2", models.Match{Id: "20", State: models.MatchStatePending, Player1Name: "Armada", Player2Name: models.PlayerTBD, Station: "2"})
+			},
+			wantTypes: []models.MatchEventType{models.MatchEventUnderway, models.MatchEventStationAssigned},
+		},
+		{
+			testName: "It should send a completed match to a view that does not show complete matches",
+			view:     View{States: []models.MatchState{models.MatchStateOpen}},
+			change: func(mockFetchData *stubFetchData) {
+				mockFetchData.setMatch("1", models.Match{Id: "10", State: models.MatchStateComplete, Player1Name: "Mango", Player2Name: "Zain", Winner: 1})
+			},
+			wantTypes: []models.MatchEventType{models.MatchEventCompleted},
+		},
+		{
+			testName: "It should send a match that is neither open nor complete anymore as removed",
+			view:     View{States: []models.MatchState{models.MatchStateOpen}},
+			change: func(mockFetchData *stubFetchData) {
+				mockFetchData.mu.Lock()
+				mockFetchData.matches["1"] = mockFetchData.matches["1"][1:]
+				mockFetchData.mu.Unlock()
+			},
+			wantTypes: []models.MatchEventType{models.MatchEventRemoved},
+		},
+		{
+			testName: "It should send the matches of a tournament that is gone as removed",
+			view:     View{},
+			change: func(mockFetchData *stubFetchData) {
+				mockFetchData.mu.Lock()
+				delete(mockFetchData.tournaments, "2")
+				mockFetchData.mu.Unlock()
+			},
+			wantTypes: []models.MatchEventType{models.MatchEventRemoved},
+		},
+		{
+			testName: "It should keep the matches of a tournament that failed",
+			view:     View{},
+			change: func(mockFetchData *stubFetchData) {
+				mockFetchData.mu.Lock()
+				mockFetchData.failingMatches = map[string]error{"2": errors.New("matches failed")}
+				mockFetchData.mu.Unlock()
+			},
+			wantTypes: []models.MatchEventType{},
+		},
+		{
+			testName:  "It should only send the tournaments the view selects",
+			view:      View{Select: func(gameName, tournamentId string) bool { return tournamentId == "2" }},
+			change:    func(mockFetchData *stubFetchData) { mockFetchData.setMatch("1", open) },
+			wantTypes: []models.MatchEventType{},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testName, func(t *testing.T) {
+			// Given
+			matchWatcher, mockFetchData := newTestWatcher(t)
+			subscription := subscribe(t, matchWatcher, "", tc.view)
+			tc.change(mockFetchData)
+			// When
+			matchWatcher.pollFeeds(context.Background())
+			// Then
+			gotTypes := []models.MatchEventType{}
+			for len(subscription.Events) > 0 {
+				gotTypes = append(gotTypes, (<-subscription.Events).Type)
+			}
+			assert.Equal(t, tc.wantTypes, gotTypes)
+		})
+	}
+}
+
+func TestPollStates(t *testing.T) {
+	openView := View{States: []models.MatchState{models.MatchStateOpen}}
+	completed := models.Match{Id: "10", State: models.MatchStateComplete, Player1Name: "Mango", Player2Name: "Zain", Winner: 1}
+
+	t.Run("It should only fetch the states subscribers are shown, a request per state", func(t *testing.T) {
+		// Given
+		matchWatcher, mockFetchData := newTestWatcher(t, WithCompleteInterval(time.Hour))
+		subscribe(t, matchWatcher, "", openView)
+		mockFetchData.takeRequestedStates()
+		// When
+		matchWatcher.pollFeeds(context.Background())
+		// Then
+		assert.Equal(t, [][]models.MatchState{{models.MatchStateOpen}, {models.MatchStateOpen}}, mockFetchData.takeRequestedStates())
+	})
+
+	t.Run("It should fetch complete matches once the complete interval has passed", func(t *testing.T) {
+		// Given
+		matchWatcher, mockFetchData := newTestWatcher(t)
+		subscribe(t, matchWatcher, "", openView)
+		mockFetchData.takeRequestedStates()
+		// When
+		matchWatcher.pollFeeds(context.Background())
+		// Then
+		assert.ElementsMatch(t, [][]models.MatchState{
+			{models.MatchStateComplete}, {models.MatchStateComplete}, {models.MatchStateOpen}, {models.MatchStateOpen},
+		}, mockFetchData.takeRequestedStates())
+	})
+
+	t.Run("It should poll straight away for a subscriber shown a state that is not polled", func(t *testing.T) {
+		// Given
+		matchWatcher, _ := newTestWatcher(t, WithCompleteInterval(time.Hour))
+		subscribe(t, matchWatcher, "", openView)
+		// When
+		subscription := subscribe(t, matchWatcher, "", View{States: []models.MatchState{models.MatchStatePending}})
+		// Then
+		require.NotNil(t, subscription.Snapshot)
+		require.Len(t, subscription.Snapshot.Tournaments, 2)
+		assert.Equal(t, "11", subscription.Snapshot.Tournaments[0].MatchList[0].Id)
+		assert.Equal(t, "20", subscription.Snapshot.Tournaments[1].MatchList[0].Id)
+	})
+
+	t.Run("It should hold an open match that is gone until the complete matches show it completed", func(t *testing.T) {
+		// Given
+		matchWatcher, mockFetchData := newTestWatcher(t, WithCompleteInterval(time.Hour))
+		subscription := subscribe(t, matchWatcher, "", openView)
+		mockFetchData.setMatch("1", completed)
+		matchWatcher.pollFeeds(context.Background())
+		require.Empty(t, subscription.Events)
+		// When
+		matchWatcher.completeInterval = 0
+		matchWatcher.pollFeeds(context.Background())
+		// Then
+		require.Len(t, subscription.Events, 1)
+		event := <-subscription.Events
+		assert.Equal(t, models.MatchEventCompleted, event.Type)
+		assert.Equal(t, completed, event.Match)
+	})
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	// Given
+	matchWatcher, mockFetchData := newTestWatcher(t, WithSubscriberBuffer(1))
+	slow := subscribe(t, matchWatcher, "", View{})
+	keepingUp := subscribe(t, matchWatcher, "", View{})
+	// When
+	for _, station := range []string{"1", "2"} {
+		mockFetchData.setMatch("1", models.Match{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", Station: station})
+		matchWatcher.pollFeeds(context.Background())
+		<-keepingUp.Events
+	}
+	// Then
+	_, ok := <-slow.Events
+	assert.True(t, ok)
+	_, ok = <-slow.Events
+	assert.False(t, ok, "the slow subscriber should have been dropped")
+}
+
+func TestReselect(t *testing.T) {
+	// Given
+	matchWatcher, mockFetchData := newTestWatcher(t)
+	subscription := subscribe(t, matchWatcher, "", View{
+		Select: func(gameName, tournamentId string) bool { return tournamentId == "1" },
+	})
+	// When
+	snapshot := subscription.Reselect(func(gameName, tournamentId string) bool { return tournamentId == "2" })
+	mockFetchData.setMatch("1", models.Match{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", Underway: true})
+	mockFetchData.setMatch("2", models.Match{Id: "20", State: models.MatchStateOpen, Player1Name: "Armada", Player2Name: "Plup"})
+	matchWatcher.pollFeeds(context.Background())
+	// Then
+	require.Len(t, snapshot.Tournaments, 1, "the snapshot should only hold the tournament newly selected")
+	assert.Equal(t, "2", snapshot.Tournaments[0].TournamentId)
+	assert.Equal(t, subscription.Snapshot.EventId, snapshot.EventId)
+	event := <-subscription.Events
+	assert.Equal(t, "2", event.TournamentId)
+	assert.Equal(t, models.MatchEventUpdated, event.Type)
+	assert.Empty(t, subscription.Events, "the tournament no longer selected should not send events")
+}
+
+func TestRun(t *testing.T) {
+	t.Run("It should close every subscription and refuse new ones once stopped", func(t *testing.T) {
+		// Given
+		matchWatcher, _ := newTestWatcher(t)
+		subscription := subscribe(t, matchWatcher, "", View{})
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			matchWatcher.Run(ctx)
+			close(done)
+		}()
+		// When
+		cancel()
+		<-done
+		// Then
+		_, ok := <-subscription.Events
+		assert.False(t, ok)
+		_, err := matchWatcher.Subscribe(context.Background(), testFilter, "", View{})
+		assert.ErrorIs(t, err, ErrStopped)
+	})
+
+	t.Run("It should drop a feed left idle for longer than the idle timeout", func(t *testing.T) {
+		// Given
+		matchWatcher, _ := newTestWatcher(t, WithIdleTimeout(time.Nanosecond))
+		subscription := subscribe(t, matchWatcher, "", View{})
+		subscription.Close()
+		time.Sleep(time.Millisecond)
+		// When
+		matchWatcher.pollFeeds(context.Background())
+		// Then
+		matchWatcher.mu.Lock()
+		defer matchWatcher.mu.Unlock()
+		assert.Empty(t, matchWatcher.feeds)
+	})
+}
+
+func newTestWatcher(t *testing.T, opts ...Option) (*Watcher, *stubFetchData) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mockFetchData := &stubFetchData{
+		tournaments: map[string]string{"1": "game1", "2": "game2"},
+		matches: map[string][]models.Match{
+			"1": {
+				{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain"},
+				{Id: "11", State: models.MatchStatePending, Player1Name: "Armada", Player2Name: models.PlayerTBD},
+			},
+			"2": {
+				{Id: "20", State: models.MatchStatePending, Player1Name: "Armada", Player2Name: models.PlayerTBD},
+			},
+		},
+	}
+	// every poll fetches again, complete matches included, so the stub's changes are seen straight away
+	mockCache := cache.NewCache(0, time.Hour, logger, cache.WithMaxStaleness(0), cache.WithMatchTTL(0))
+	return New(mockCache, mockFetchData, time.Hour, logger, append([]Option{WithCompleteInterval(0)}, opts...)...), mockFetchData
+}
+
+func subscribe(t *testing.T, matchWatcher *Watcher, lastEventId string, view View) *Subscription {
+	t.Helper()
+	subscription, err := matchWatcher.Subscribe(context.Background(), testFilter, lastEventId, view)
+	require.NoError(t, err)
+	t.Cleanup(subscription.Close)
+	return subscription
+}
+
+type stubFetchData struct {
+	mu                 sync.Mutex
+	tournaments        map[string]string
+	matches            map[string][]models.Match
+	failingTournaments error
+	failingMatches     map[string]error
+	// requestedStates holds the states of every match fetch
+	requestedStates [][]models.MatchState
+}
+
+// takeRequestedStates returns the states of the match fetches made since it was last called
+func (s *stubFetchData) takeRequestedStates() [][]models.MatchState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requestedStates := s.requestedStates
+	s.requestedStates = nil
+	return requestedStates
+}
+
+// setMatch replaces the match with the same id in the tournament's match list
+func (s *stubFetchData) setMatch(tournamentId string, match models.Match) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches := slices.Clone(s.matches[tournamentId])
+	index := slices.IndexFunc(matches, func(m models.Match) bool { return m.Id == match.Id })
+	if index < 0 {
+		matches = append(matches, match)
+	} else {
+		matches[index] = match
+	}
+	s.matches[tournamentId] = matches
+}
+
+func (s *stubFetchData) FetchTournaments(ctx context.Context, filter models.TournamentFilter) (map[string]models.TournamentSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failingTournaments != nil {
+		return nil, s.failingTournaments
+	}
+	ret := map[string]models.TournamentSummary{}
+	for tournamentId, tournamentGame := range s.tournaments {
+		ret[tournamentId] = models.TournamentSummary{Id: tournamentId, GameName: tournamentGame}
+	}
+	return ret, nil
+}
+
+func (s *stubFetchData) FetchTournament(ctx context.Context, tournamentId string) (models.TournamentSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return models.TournamentSummary{Id: tournamentId, GameName: s.tournaments[tournamentId]}, nil
+}
+
+func (s *stubFetchData) FetchParticipants(ctx context.Context, tournamentId, tournamentGame string) (models.TournamentParticipants, error) {
+	return models.TournamentParticipants{
+		GameName:     tournamentGame,
+		TournamentID: tournamentId,
+		Participant:  map[string]string{},
+	}, nil
+}
+
+func (s *stubFetchData) FetchMatches(ctx context.Context, tournamentParticipants models.TournamentParticipants, states []models.MatchState) (models.TournamentMatches, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requestedStates = append(s.requestedStates, states)
+	if err, ok := s.failingMatches[tournamentParticipants.TournamentID]; ok {
+		return models.TournamentMatches{}, err
+	}
+	matchList := []models.Match{}
+	for _, match := range s.matches[tournamentParticipants.TournamentID] {
+		if slices.Contains(states, match.State) {
+			matchList = append(matchList, match)
+		}
+	}
+	return models.TournamentMatches{
+		GameName:     tournamentParticipants.GameName,
+		TournamentId: tournamentParticipants.TournamentID,
+		MatchList:    matchList,
+	}, nil
+}
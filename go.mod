@@ -9,6 +9,14 @@ require (
 
 require github.com/go-chi/cors v1.2.1
 
+require (
+	github.com/gorilla/websocket v1.5.3
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/time v0.5.0
+)
+
+require golang.org/x/sys v0.4.0 // indirect
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/go-chi/httplog/v2 v2.0.7
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
 	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/watcher"
 	"github.com/MarcBernstein0/pending-matches/route"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -64,8 +71,68 @@ func main() {
 		log.Fatalf("cacheTimer could not be read properly\n%s", err)
 	}
 
-	customClient := challongebracketmatches.New("https://api.challonge.com/v2.1", apiKey, http.DefaultClient, 20*time.Minute)
-	customCache := cache.NewCache(time.Duration(cacheTimer)*time.Minute, time.Duration(cacheClearTimer)*time.Hour, logger.Logger)
+	retryPolicy := challongebracketmatches.DefaultRetryPolicy
+	retryPolicy.MaxAttempts = envInt("RETRY_MAX_ATTEMPTS", retryPolicy.MaxAttempts)
+
+	rateLimit := envFloat("RATE_LIMIT_PER_SECOND", 5)
+	rateLimitBurst := envInt("RATE_LIMIT_BURST", 10)
+	maxConcurrentRequests := envInt("MAX_CONCURRENT_REQUESTS", 8)
+
+	customClient := challongebracketmatches.New("https://api.challonge.com/v2.1", apiKey, http.DefaultClient, 20*time.Minute,
+		challongebracketmatches.WithRetryPolicy(retryPolicy),
+		challongebracketmatches.WithRateLimit(rateLimit, rateLimitBurst, maxConcurrentRequests))
+	var cacheStore cache.Store
+	switch cacheStoreType := envString("CACHE_STORE", "memory"); cacheStoreType {
+	case "memory":
+		cacheStore = cache.NewMemoryStore()
+	case "bolt":
+		cacheStore, err = cache.NewBoltStore(envString("CACHE_STORE_PATH", "cache.db"))
+		if err != nil {
+			log.Fatalf("cache store could not be opened\n%s", err)
+		}
+	default:
+		log.Fatalf("CACHE_STORE must be memory or bolt, got %q", cacheStoreType)
+	}
+	defer cacheStore.Close()
+
+	cacheMaxStaleness := time.Duration(envInt("CACHE_MAX_STALENESS", int(cache.DefaultMaxStaleness.Minutes()))) * time.Minute
+	rosterRefresh := time.Duration(envInt("CACHE_ROSTER_REFRESH", int(cache.DefaultRosterRefresh.Minutes()))) * time.Minute
+	matchCacheTTL := time.Duration(envInt("MATCH_CACHE_TTL", int(cache.DefaultMatchTTL.Seconds()))) * time.Second
+	customCache := cache.NewCache(time.Duration(cacheTimer)*time.Minute, time.Duration(cacheClearTimer)*time.Hour, logger.Logger,
+		cache.WithMaxStaleness(cacheMaxStaleness),
+		cache.WithStore(cacheStore),
+		cache.WithMatchTTL(matchCacheTTL),
+		cache.WithRefreshTimeout(customClient.Timeout()),
+		cache.WithRosterRefresh(rosterRefresh))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+	// the warmer is off unless an interval is configured
+	warmInterval := time.Duration(envInt("CACHE_WARM_INTERVAL", 0)) * time.Second
+	if warmInterval > 0 {
+		location, err := time.LoadLocation(envString("EVENT_TIMEZONE", "Local"))
+		if err != nil {
+			log.Fatalf("EVENT_TIMEZONE could not be read properly\n%s", err)
+		}
+		warmDates := strings.Split(envString("CACHE_WARM_DATES", "today"), ",")
+		warmer := cache.NewWarmer(customCache, customClient, warmDates, location, warmInterval, warmInterval/10, logger.Logger)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			warmer.Run(ctx)
+		}()
+	}
+
+	// a single watcher polls the matches streamed to every client
+	matchWatcher := watcher.New(customCache, customClient, time.Duration(envInt("WATCH_INTERVAL", 10))*time.Second, logger.Logger,
+		watcher.WithCompleteInterval(time.Duration(envInt("WATCH_COMPLETE_INTERVAL", int(watcher.DefaultCompleteInterval.Seconds())))*time.Second))
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		matchWatcher.Run(ctx)
+	}()
 
 	// chi service
 	r := chi.NewRouter()
@@ -83,15 +150,69 @@ func main() {
 		AllowedOrigins: []string{"https://*", "http://*"},
 		// AllowOriginFunc:  func(r *http.Request, origin string) bool { return true },
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Last-Event-ID"},
+		ExposedHeaders:   []string{"Link", "X-Data-Age"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
 
-	api := route.RouterSetup(customClient, customCache)
+	// the cache admin endpoints are only served when a token is configured
+	api := route.RouterSetup(customClient, customCache,
+		route.WithAdminToken(envString("ADMIN_TOKEN", "")),
+		route.WithMatchWatcher(matchWatcher, time.Duration(envInt("STREAM_HEARTBEAT", int(route.DefaultHeartbeat.Seconds())))*time.Second))
 
 	r.Mount("/", api)
+
+	server := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		<-ctx.Done()
+		logger.Info("pending match server shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("pending match server shutdown", "error", err)
+		}
+	}()
+
 	logger.Info("pending match server started")
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	wg.Wait()
+	logger.Info("pending match server stopped")
+}
+
+// envString reads a string env variable, falling back to def when it is not set
+func envString(key string, def string) string {
+	value, present := os.LookupEnv(key)
+	if !present {
+		return def
+	}
+	return value
+}
+
+// envInt reads an integer env variable, falling back to def when it is not set
+func envInt(key string, def int) int {
+	valueString, present := os.LookupEnv(key)
+	if !present {
+		return def
+	}
+	value, err := strconv.Atoi(valueString)
+	if err != nil {
+		log.Fatalf("%s could not be read properly\n%s", key, err)
+	}
+	return value
+}
+
+// envFloat reads a float env variable, falling back to def when it is not set
+func envFloat(key string, def float64) float64 {
+	valueString, present := os.LookupEnv(key)
+	if !present {
+		return def
+	}
+	value, err := strconv.ParseFloat(valueString, 64)
+	if err != nil {
+		log.Fatalf("%s could not be read properly\n%s", key, err)
+	}
+	return value
 }
@@ -0,0 +1,25 @@
+package models
+
+// MatchEventType says how a match changed for the client it is sent to
+type MatchEventType string
+
+const (
+	// MatchEventAdded is sent for a match that entered the states the client watches, like a pending match opening
+	MatchEventAdded           MatchEventType = "match_added"
+	MatchEventUnderway        MatchEventType = "match_underway"
+	MatchEventStationAssigned MatchEventType = "station_assigned"
+	MatchEventCompleted       MatchEventType = "match_completed"
+	// MatchEventUpdated is sent for any other change, like a player being decided or a score being reported
+	MatchEventUpdated MatchEventType = "match_updated"
+	// MatchEventRemoved is sent for a match that left the states the client watches without completing, or was deleted
+	MatchEventRemoved MatchEventType = "match_removed"
+)
+
+// MatchEvent carries the match as it is after the change, or as it was last seen when it was removed
+type MatchEvent struct {
+	Id           string         `json:"id"`
+	Type         MatchEventType `json:"type"`
+	GameName     string         `json:"game_name"`
+	TournamentId string         `json:"tournament_id"`
+	Match        Match          `json:"match"`
+}
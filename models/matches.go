@@ -6,6 +6,8 @@ type (
 	Matches struct {
 		Data     []ChallongeMatch `json:"data"`
 		Included []Included       `json:"included"`
+		Meta     Meta             `json:"meta"`
+		Links    Links            `json:"links"`
 	}
 
 	ChallongeMatch struct {
@@ -26,14 +28,31 @@ type (
 		Id string `json:"id"`
 	}
 
+	// MatchAttributes only lists the participants already decided in PointsByParticipant, the matches
+	// undecided players will come from are given by the prereq fields
 	MatchAttributes struct {
-		Round               int                   `json:"round"`
-		SuggestedPlayOrder  int                   `json:"suggested_play_order"`
-		PointsByParticipant []PointsByParticipant `json:"points_by_participant"`
-		Timestamps          TimeStamps            `json:"timestamps"`
+		State                     MatchState            `json:"state"`
+		Round                     int                   `json:"round"`
+		Identifier                string                `json:"identifier"`
+		Scores                    string                `json:"scores"`
+		SuggestedPlayOrder        int                   `json:"suggested_play_order"`
+		ScoreInSets               [][]int               `json:"score_in_sets"`
+		PointsByParticipant       []PointsByParticipant `json:"points_by_participant"`
+		Timestamps                TimeStamps            `json:"timestamps"`
+		WinnerId                  *int                  `json:"winner_id"`
+		Tie                       bool                  `json:"tie"`
+		Player1PrereqMatchId      *int                  `json:"player1_prereq_match_id"`
+		Player1IsPrereqMatchLoser bool                  `json:"player1_is_prereq_match_loser"`
+		Player2PrereqMatchId      *int                  `json:"player2_prereq_match_id"`
+		Player2IsPrereqMatchLoser bool                  `json:"player2_is_prereq_match_loser"`
+		GroupId                   *int                  `json:"group_id"`
 	}
 
+	// TimeStamps are zero when Challonge sends null
 	TimeStamps struct {
+		StartedAt  time.Time `json:"started_at"`
+		CreatedAt  time.Time `json:"created_at"`
+		UpdatedAt  time.Time `json:"updated_at"`
 		UnderwayAt time.Time `json:"underway_at"`
 	}
 
@@ -0,0 +1,13 @@
+package models
+
+type (
+	Links struct {
+		Self string `json:"self"`
+		Next string `json:"next"`
+		Prev string `json:"prev"`
+	}
+
+	Meta struct {
+		Count int `json:"count"`
+	}
+)
@@ -2,7 +2,14 @@ package models
 
 type (
 	Participants struct {
-		Data []Participant `json:"data"`
+		Data  []Participant `json:"data"`
+		Meta  Meta          `json:"meta"`
+		Links Links         `json:"links"`
+	}
+
+	// ParticipantResponse is returned when a single participant is requested by id
+	ParticipantResponse struct {
+		Data Participant `json:"data"`
 	}
 
 	Participant struct {
@@ -12,6 +19,8 @@ type (
 
 	ParticipantAttributes struct {
 		Name string `json:"name"`
+		// Username is the Challonge account the participant registered with, nil for participants added by name only
+		Username *string `json:"username"`
 	}
 )
 
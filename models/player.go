@@ -0,0 +1,59 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+type (
+	// PlayerMatch is an open or pending match of a player looked up by name
+	PlayerMatch struct {
+		GameName     string `json:"game_name"`
+		TournamentId string `json:"tournament_id"`
+		// Player is the name the player is registered under, Opponent who they are facing which may be PlayerTBD
+		Player   string `json:"player"`
+		Opponent string `json:"opponent"`
+		Match    Match  `json:"match"`
+		// MatchesAhead counts the open and pending matches of the tournament suggested to be played before this one
+		MatchesAhead int `json:"matches_ahead"`
+	}
+
+	// PlayerMatchesResponse is returned by /api/v1/players/{name}/matches. Partial is set the same way as in MatchesResponse
+	PlayerMatchesResponse struct {
+		Matches []PlayerMatch     `json:"matches"`
+		Errors  []TournamentError `json:"errors"`
+		Partial bool              `json:"partial"`
+	}
+
+	// PlayerConflict is a player called to more than one open match at once, usually across the brackets of different games
+	PlayerConflict struct {
+		// Player is the name the player is registered under in the soonest of their matches, Tag what it normalizes to
+		Player string `json:"player"`
+		Tag    string `json:"tag"`
+		// Username is the Challonge account linking the player's entries, empty when they were only matched on their tag
+		Username string        `json:"username,omitempty"`
+		Matches  []PlayerMatch `json:"matches"`
+	}
+
+	// ConflictsResponse is returned by /api/v1/conflicts. Partial and Stale are set the same way as in MatchesResponse
+	ConflictsResponse struct {
+		Conflicts []PlayerConflict  `json:"conflicts"`
+		Errors    []TournamentError `json:"errors"`
+		Partial   bool              `json:"partial"`
+		Stale     bool              `json:"stale"`
+	}
+)
+
+// NormalizeTag reduces a participant name to the player's tag for comparison, dropping any sponsor prefix
+// given before a "|" along with case, spaces and punctuation. "TSM | Leffen" and "leffen" give the same tag
+func NormalizeTag(name string) string {
+	if index := strings.LastIndex(name, "|"); index >= 0 {
+		name = name[index+1:]
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	// Given
+	tt := []struct {
+		testName string
+		mockName string
+		wantTag  string
+	}{
+		{
+			testName: "case is ignored",
+			mockName: "LeFFen",
+			wantTag:  "leffen",
+		},
+		{
+			testName: "sponsor prefix is dropped",
+			mockName: "TSM | Leffen",
+			wantTag:  "leffen",
+		},
+		{
+			testName: "only the last sponsor separator counts",
+			mockName: "Team | Sub | Leffen",
+			wantTag:  "leffen",
+		},
+		{
+			testName: "spaces and punctuation are dropped",
+			mockName: " Mr. Game & Watch ",
+			wantTag:  "mrgamewatch",
+		},
+		{
+			testName: "letters outside ascii are kept",
+			mockName: "Tokidoki ときど",
+			wantTag:  "tokidokiときど",
+		},
+		{
+			testName: "nothing left",
+			mockName: "SPON | ...",
+			wantTag:  "",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When
+			gotTag := NormalizeTag(tc.mockName)
+
+			// Then
+			assert.Equal(t, tc.wantTag, gotTag)
+		})
+	}
+}
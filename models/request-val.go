@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 )
@@ -16,25 +17,28 @@ const (
 var (
 	ErrorDateNotProvided     = errors.New("date query parameter not provided")
 	ErrorDateIncorrectFormat = errors.New("incorrect date format")
+	ErrorInvalidMatchState   = errors.New("state must be one or more of open, pending, complete")
 )
 
+// DefaultMatchStates are the match states returned when no state query parameter is given
+var DefaultMatchStates = []MatchState{MatchStateOpen}
+
 type (
 	TournamentOrganizer int
 
 	RequestValues struct {
-		Date     string
+		Filter   TournamentFilter
 		GameList []string
+		// States is sorted and holds no duplicates
+		States []MatchState
 	}
 )
 
 func CreateRequestValues(urlValues url.Values) (RequestValues, error) {
 
-	dateStr := urlValues.Get("date")
-	if dateStr == "" {
-		return RequestValues{}, ErrorDateNotProvided
-	}
-	if _, err := time.Parse("2006-01-02", dateStr); err != nil {
-		return RequestValues{}, ErrorDateIncorrectFormat
+	filter, err := CreateTournamentFilter(urlValues)
+	if err != nil {
+		return RequestValues{}, err
 	}
 
 	gamesListStr := urlValues.Get("games")
@@ -44,8 +48,48 @@ func CreateRequestValues(urlValues url.Values) (RequestValues, error) {
 		gamesList = strings.Split(gamesListStr, ",")
 	}
 
+	states, err := ParseMatchStates(urlValues["state"])
+	if err != nil {
+		return RequestValues{}, err
+	}
+
 	return RequestValues{
-		Date:     dateStr,
+		Filter:   filter,
 		GameList: gamesList,
+		States:   states,
 	}, nil
 }
+
+// ValidateDate checks dateStr is given in the YYYY-MM-DD format dates are cached under
+func ValidateDate(dateStr string) error {
+	if dateStr == "" {
+		return ErrorDateNotProvided
+	}
+	if _, err := time.Parse("2006-01-02", dateStr); err != nil {
+		return ErrorDateIncorrectFormat
+	}
+	return nil
+}
+
+// ParseMatchStates reads the state query parameter, given either comma separated or repeated
+func ParseMatchStates(values []string) ([]MatchState, error) {
+	var states []MatchState
+	for _, value := range values {
+		for _, stateStr := range strings.Split(value, ",") {
+			state := MatchState(strings.TrimSpace(stateStr))
+			switch state {
+			case MatchStateOpen, MatchStatePending, MatchStateComplete:
+			default:
+				return nil, ErrorInvalidMatchState
+			}
+			if !slices.Contains(states, state) {
+				states = append(states, state)
+			}
+		}
+	}
+	if len(states) == 0 {
+		return slices.Clone(DefaultMatchStates), nil
+	}
+	slices.Sort(states)
+	return states, nil
+}
@@ -22,11 +22,77 @@ func TestCreateRequestVal(t *testing.T) {
 				"games": []string{"game1,game2,game3"},
 			},
 			wantData: RequestValues{
-				Date:     "2006-01-02",
+				Filter:   DateFilter("2006-01-02"),
 				GameList: []string{"game1", "game2", "game3"},
+				States:   []MatchState{MatchStateOpen},
 			},
 			wantErr: nil,
 		},
+		{
+			testName: "match states provided",
+			mockData: url.Values{
+				"date":  []string{"2006-01-02"},
+				"state": []string{"pending,open", "complete", "open"},
+			},
+			wantData: RequestValues{
+				Filter: DateFilter("2006-01-02"),
+				States: []MatchState{MatchStateComplete, MatchStateOpen, MatchStatePending},
+			},
+			wantErr: nil,
+		},
+		{
+			testName: "invalid match state",
+			mockData: url.Values{
+				"date":  []string{"2006-01-02"},
+				"state": []string{"open,underway"},
+			},
+			wantData: RequestValues{},
+			wantErr:  ErrorInvalidMatchState,
+		},
+		{
+			testName: "date window and tournament state provided",
+			mockData: url.Values{
+				"created_after":    []string{"2006-01-01"},
+				"created_before":   []string{"2006-01-03"},
+				"tournament_state": []string{"pending"},
+			},
+			wantData: RequestValues{
+				Filter: TournamentFilter{
+					CreatedAfter:  "2006-01-01",
+					CreatedBefore: "2006-01-03",
+					State:         TournamentStatePending,
+				},
+				States: []MatchState{MatchStateOpen},
+			},
+			wantErr: nil,
+		},
+		{
+			testName: "created_before not after created_after",
+			mockData: url.Values{
+				"created_after":  []string{"2006-01-02"},
+				"created_before": []string{"2006-01-02"},
+			},
+			wantData: RequestValues{},
+			wantErr:  ErrorDateWindowReversed,
+		},
+		{
+			testName: "created_before not formatted correctly",
+			mockData: url.Values{
+				"date":           []string{"2006-01-02"},
+				"created_before": []string{"01-03-2006"},
+			},
+			wantData: RequestValues{},
+			wantErr:  ErrorDateIncorrectFormat,
+		},
+		{
+			testName: "invalid tournament state",
+			mockData: url.Values{
+				"date":             []string{"2006-01-02"},
+				"tournament_state": []string{"underway"},
+			},
+			wantData: RequestValues{},
+			wantErr:  ErrorInvalidTournamentState,
+		},
 		{
 			testName: "no date provided",
 			mockData: url.Values{
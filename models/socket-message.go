@@ -0,0 +1,42 @@
+package models
+
+// SocketAction is what a websocket client asks for
+type SocketAction string
+
+const (
+	SocketSubscribe   SocketAction = "subscribe"
+	SocketUnsubscribe SocketAction = "unsubscribe"
+)
+
+// SocketMessageType tells the messages a websocket client is sent apart from match events
+type SocketMessageType string
+
+const (
+	// SocketSubscribed answers every subscribe and unsubscribe, and is sent once the connection opens
+	SocketSubscribed SocketMessageType = "subscribed"
+	// SocketError answers a message the server could not make sense of, the connection stays open
+	SocketError SocketMessageType = "error"
+)
+
+type (
+	// SubscriptionRequest adds or removes games and tournaments from the ones a websocket client is sent the events of
+	SubscriptionRequest struct {
+		Action        SocketAction `json:"action"`
+		Games         []string     `json:"games"`
+		TournamentIds []string     `json:"tournament_ids"`
+	}
+
+	// SubscribedMessage lists everything the client is subscribed to after a request, with the matches of the games
+	// and tournaments the request added as of the events that follow it
+	SubscribedMessage struct {
+		Type          SocketMessageType `json:"type"`
+		Games         []string          `json:"games"`
+		TournamentIds []string          `json:"tournament_ids"`
+		Snapshot      MatchesResponse   `json:"snapshot"`
+	}
+
+	SocketErrorMessage struct {
+		Type    SocketMessageType `json:"type"`
+		Message string            `json:"message"`
+	}
+)
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// TournamentState is the state Challonge reports for a tournament
+type TournamentState string
+
+const (
+	TournamentStatePending    TournamentState = "pending"
+	TournamentStateInProgress TournamentState = "in_progress"
+	TournamentStateEnded      TournamentState = "ended"
+)
+
+var (
+	ErrorInvalidTournamentState = errors.New("tournament_state must be one of pending, in_progress, ended")
+	ErrorDateWindowReversed     = errors.New("created_before must be after created_after")
+)
+
+// TournamentFilter selects the tournaments to load, every tournament in State created inside the date window.
+// An empty State is treated as in progress and an empty CreatedBefore leaves the window open
+type TournamentFilter struct {
+	CreatedAfter  string          `json:"created_after"`
+	CreatedBefore string          `json:"created_before,omitempty"`
+	State         TournamentState `json:"tournament_state"`
+}
+
+// DateFilter selects the tournaments in progress created after date, what a date query parameter asks for
+func DateFilter(date string) TournamentFilter {
+	return TournamentFilter{
+		CreatedAfter: date,
+		State:        TournamentStateInProgress,
+	}
+}
+
+// TournamentState returns the state to filter on, in progress when none was set
+func (f TournamentFilter) TournamentState() TournamentState {
+	if f.State == "" {
+		return TournamentStateInProgress
+	}
+	return f.State
+}
+
+// Key identifies the filter in a cache. The key of DateFilter(date) is date itself
+func (f TournamentFilter) Key() string {
+	key := f.CreatedAfter
+	if f.CreatedBefore != "" {
+		key += ".." + f.CreatedBefore
+	}
+	if state := f.TournamentState(); state != TournamentStateInProgress {
+		key += "|" + string(state)
+	}
+	return key
+}
+
+// CreateTournamentFilter reads created_after, or date for short, created_before and tournament_state
+func CreateTournamentFilter(urlValues url.Values) (TournamentFilter, error) {
+	createdAfter := urlValues.Get("created_after")
+	if createdAfter == "" {
+		createdAfter = urlValues.Get("date")
+	}
+	if err := ValidateDate(createdAfter); err != nil {
+		return TournamentFilter{}, err
+	}
+
+	createdBefore := urlValues.Get("created_before")
+	if createdBefore != "" {
+		if err := ValidateDate(createdBefore); err != nil {
+			return TournamentFilter{}, err
+		}
+		// dates in the YYYY-MM-DD format sort the same as strings
+		if createdBefore <= createdAfter {
+			return TournamentFilter{}, ErrorDateWindowReversed
+		}
+	}
+
+	state := TournamentState(strings.TrimSpace(urlValues.Get("tournament_state")))
+	switch state {
+	case "":
+		state = TournamentStateInProgress
+	case TournamentStatePending, TournamentStateInProgress, TournamentStateEnded:
+	default:
+		return TournamentFilter{}, ErrorInvalidTournamentState
+	}
+
+	return TournamentFilter{
+		CreatedAfter:  createdAfter,
+		CreatedBefore: createdBefore,
+		State:         state,
+	}, nil
+}
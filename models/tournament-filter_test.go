@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTournamentFilterKey(t *testing.T) {
+	// Given
+	tt := []struct {
+		testName   string
+		mockFilter TournamentFilter
+		wantKey    string
+	}{
+		{
+			testName:   "date filter is keyed by its date",
+			mockFilter: DateFilter("2006-01-02"),
+			wantKey:    "2006-01-02",
+		},
+		{
+			testName:   "empty state is keyed as in progress",
+			mockFilter: TournamentFilter{CreatedAfter: "2006-01-02"},
+			wantKey:    "2006-01-02",
+		},
+		{
+			testName:   "date window",
+			mockFilter: TournamentFilter{CreatedAfter: "2006-01-01", CreatedBefore: "2006-01-03", State: TournamentStateInProgress},
+			wantKey:    "2006-01-01..2006-01-03",
+		},
+		{
+			testName:   "date window and state",
+			mockFilter: TournamentFilter{CreatedAfter: "2006-01-01", CreatedBefore: "2006-01-03", State: TournamentStateEnded},
+			wantKey:    "2006-01-01..2006-01-03|ended",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When
+			gotKey := tc.mockFilter.Key()
+
+			// Then
+			assert.Equal(t, tc.wantKey, gotKey)
+		})
+	}
+}
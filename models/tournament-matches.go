@@ -1,19 +1,89 @@
 package models
 
+import "time"
+
+// MatchState is the state Challonge reports for a match. A match is pending until both players are decided,
+// open until its result is reported and complete afterwards
+type MatchState string
+
+const (
+	MatchStatePending  MatchState = "pending"
+	MatchStateOpen     MatchState = "open"
+	MatchStateComplete MatchState = "complete"
+)
+
+// names shown for players that are not decided yet
+const (
+	PlayerTBD = "TBD"
+	PlayerBye = "Bye"
+)
+
 type (
+	// Match names PlayerTBD for a player still to be decided by the match given in its prerequisite match,
+	// and PlayerBye for a missing opponent when there is nothing left to decide
 	Match struct {
-		Id                 string `json:"id"`
-		Player1Name        string `json:"player1_name"`
-		Player2Name        string `json:"player2_name"`
+		Id string `json:"id"`
+		// Identifier is the match's label in the bracket, like "A12"
+		Identifier               string             `json:"identifier"`
+		State                    MatchState         `json:"state"`
+		Player1Name              string             `json:"player1_name"`
+		Player2Name              string             `json:"player2_name"`
+		Player1PrerequisiteMatch *PrerequisiteMatch `json:"player1_prerequisite_match,omitempty"`
+		Player2PrerequisiteMatch *PrerequisiteMatch `json:"player2_prerequisite_match,omitempty"`
+		// GroupId is the group of a group stage match, empty for a match of the final bracket. Round and Identifier
+		// restart in every group
+		GroupId            string `json:"group_id,omitempty"`
 		Round              int    `json:"round"`
 		SuggestedPlayOrder int    `json:"suggested_play_order"`
 		Underway           bool   `json:"underway"`
 		Station            string `json:"station"`
+		// Scores is the score as Challonge formats it, like "2 - 1", and ScoreInSets the player 1 and player 2 score of each set
+		Scores      string  `json:"scores"`
+		ScoreInSets [][]int `json:"score_in_sets"`
+		// Winner is 1 or 2 for the player that won, 0 while there is none
+		Winner int  `json:"winner,omitempty"`
+		Tie    bool `json:"tie"`
+		// StartedAt is when the match became open, unset while it is pending
+		StartedAt  *time.Time `json:"started_at,omitempty"`
+		UnderwayAt *time.Time `json:"underway_at,omitempty"`
+		UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	}
+
+	// PrerequisiteMatch is the match a player still to be decided comes from
+	PrerequisiteMatch struct {
+		MatchId string `json:"match_id"`
+		// Identifier is the match's bracket identifier, empty when the match was not fetched alongside
+		Identifier string `json:"identifier,omitempty"`
+		Loser      bool   `json:"loser"`
+		// Description reads like "Winner of match A12"
+		Description string `json:"description"`
 	}
 
 	TournamentMatches struct {
 		GameName     string  `json:"game_name"`
 		TournamentId string  `json:"tournament_id"`
 		MatchList    []Match `json:"match_list"`
+		// UnknownParticipantIds are referenced by matches but could not be found, even after looking them up one by one
+		UnknownParticipantIds []string `json:"-"`
+		// ResolvedParticipants were missing from the participants the names were looked up in and had to be fetched
+		ResolvedParticipants map[string]string `json:"-"`
+	}
+
+	// TournamentError describes a tournament whose data could not be fetched
+	TournamentError struct {
+		TournamentId string `json:"tournament_id"`
+		GameName     string `json:"game_name"`
+		Message      string `json:"message"`
+	}
+
+	// MatchesResponse is returned by /api/v1/matches. Partial is set when some tournaments are listed in Errors instead of Tournaments,
+	// Stale when the participants are being served from an older snapshot while they refresh. Conflicts lists the players
+	// of the open matches returned who are in more than one open match across every game of the filter
+	MatchesResponse struct {
+		Tournaments []TournamentMatches `json:"tournaments"`
+		Conflicts   []PlayerConflict    `json:"conflicts"`
+		Errors      []TournamentError   `json:"errors"`
+		Partial     bool                `json:"partial"`
+		Stale       bool                `json:"stale"`
 	}
 )
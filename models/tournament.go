@@ -1,21 +1,83 @@
 package models
 
+import "time"
+
 type (
 	Tournaments struct {
-		Data []Tournament `json:"data"`
+		Data  []Tournament `json:"data"`
+		Meta  Meta         `json:"meta"`
+		Links Links        `json:"links"`
+	}
+
+	// TournamentResponse is a single tournament looked up by its id
+	TournamentResponse struct {
+		Data Tournament `json:"data"`
 	}
 
 	Tournament struct {
-		Id         string               `json:"id"`
-		Attributes TournamentAttributes `json:"attributes"`
+		Id            string                  `json:"id"`
+		Attributes    TournamentAttributes    `json:"attributes"`
+		Relationships TournamentRelationships `json:"relationships"`
 	}
 
 	TournamentAttributes struct {
-		Name     string `json:"name"`
-		GameName string `json:"game_name"`
+		Name             string          `json:"name"`
+		GameName         string          `json:"game_name"`
+		TournamentType   string          `json:"tournament_type"`
+		URL              string          `json:"url"`
+		State            TournamentState `json:"state"`
+		FullChallongeURL string          `json:"full_challonge_url"`
+		// StartsAt is nil when the organiser did not schedule a start time
+		StartsAt *time.Time `json:"starts_at"`
+	}
+
+	TournamentRelationships struct {
+		Participants RelationshipLinks `json:"participants"`
+	}
+
+	// RelationshipLinks holds the number of records a relationship points to
+	RelationshipLinks struct {
+		Links struct {
+			Meta Meta `json:"meta"`
+		} `json:"links"`
+	}
+
+	// TournamentSummary describes a tournament matching the filter of a request
+	TournamentSummary struct {
+		Id               string          `json:"id"`
+		Name             string          `json:"name"`
+		URL              string          `json:"url"`
+		GameName         string          `json:"game_name"`
+		TournamentType   string          `json:"tournament_type"`
+		State            TournamentState `json:"state"`
+		Participants     int             `json:"participants"`
+		StartsAt         *time.Time      `json:"starts_at"`
+		FullChallongeURL string          `json:"full_challonge_url"`
+	}
+
+	// TournamentsResponse is returned by /api/v1/tournaments. Stale is set the same way as in MatchesResponse
+	TournamentsResponse struct {
+		Tournaments []TournamentSummary `json:"tournaments"`
+		Errors      []TournamentError   `json:"errors"`
+		Stale       bool                `json:"stale"`
 	}
 )
 
+// Summary flattens the fields of t the tournaments endpoint returns
+func (t Tournament) Summary() TournamentSummary {
+	return TournamentSummary{
+		Id:               t.Id,
+		Name:             t.Attributes.Name,
+		URL:              t.Attributes.URL,
+		GameName:         t.Attributes.GameName,
+		TournamentType:   t.Attributes.TournamentType,
+		State:            t.Attributes.State,
+		Participants:     t.Relationships.Participants.Links.Meta.Count,
+		StartsAt:         t.Attributes.StartsAt,
+		FullChallongeURL: t.Attributes.FullChallongeURL,
+	}
+}
+
 // type AutoGenerated struct {
 // 	Data []struct {
 // 		ID         string `json:"id"`
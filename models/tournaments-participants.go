@@ -4,4 +4,6 @@ type TournamentParticipants struct {
 	GameName     string            `json:"game_name"`
 	TournamentID string            `json:"tournament_id"`
 	Participant  map[string]string `json:"participant"`
+	// Usernames maps the ids of participants registered with a Challonge account to its username
+	Usernames map[string]string `json:"usernames,omitempty"`
 }
@@ -0,0 +1,148 @@
+package route
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httplog/v2"
+)
+
+var errInvalidAdminToken = errors.New("missing or invalid admin token")
+
+// AdminAuth only lets through requests carrying token as a bearer token
+func AdminAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				authErr := ErrorUnauthorized("A valid admin token is required", errInvalidAdminToken)
+				authErr.LogError(httplog.LogEntry(r.Context()))
+				authErr.JSONError(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetCacheEntries lists every tournament filter held by the cache
+func GetCacheEntries(cache *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := httplog.LogEntry(r.Context())
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		entries, err := cache.Entries()
+		if err != nil {
+			entriesErr := ErrorInternal("Error reading the cache", err)
+			entriesErr.LogError(logger)
+			entriesErr.JSONError(w)
+			return
+		}
+
+		json.NewEncoder(w).Encode(entries)
+	}
+}
+
+// DeleteCache clears the whole cache
+func DeleteCache(cache *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := httplog.LogEntry(r.Context())
+
+		cache.ClearCache()
+		logger.Info("Cache cleared by admin")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteCacheDate drops the cached data of every tournament filter starting at a single date
+func DeleteCacheDate(cache *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := httplog.LogEntry(r.Context())
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		date := chi.URLParam(r, "date")
+		if err := models.ValidateDate(date); err != nil {
+			dateErr := ErrorBadRequest(err.Error(), err)
+			dateErr.LogError(logger)
+			dateErr.JSONError(w)
+			return
+		}
+
+		if err := cache.InvalidateDate(date); err != nil {
+			invalidateErr := ErrorInternal("Error invalidating the cache", err)
+			invalidateErr.LogError(logger)
+			invalidateErr.JSONError(w)
+			return
+		}
+		logger.Info("Cache date invalidated by admin", "date", date)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteCacheTournament drops the cached data of a single tournament
+func DeleteCacheTournament(cache *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := httplog.LogEntry(r.Context())
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		tournamentId := chi.URLParam(r, "tournamentId")
+		found, err := cache.InvalidateTournament(tournamentId)
+		if err != nil {
+			invalidateErr := ErrorInternal("Error invalidating the cache", err)
+			invalidateErr.LogError(logger)
+			invalidateErr.JSONError(w)
+			return
+		}
+		if !found {
+			notFoundErr := ErrorNotFound("Tournament "+tournamentId+" is not cached", nil)
+			notFoundErr.LogError(logger)
+			notFoundErr.JSONError(w)
+			return
+		}
+		logger.Info("Cache tournament invalidated by admin", "tournament", tournamentId)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RefreshCacheDate fetches the tournaments created after a date from Challonge straight away and returns what the cache now holds for them.
+// The created_before and tournament_state query params narrow the filter refreshed the same way they do for the other endpoints
+func RefreshCacheDate(fetchData challongebracketmatches.FetchData, cache *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := httplog.LogEntry(r.Context())
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		date := chi.URLParam(r, "date")
+		values := r.URL.Query()
+		values.Set("created_after", date)
+		filter, err := models.CreateTournamentFilter(values)
+		if err != nil {
+			filterErr := ErrorBadRequest(err.Error(), err)
+			filterErr.LogError(logger)
+			filterErr.JSONError(w)
+			return
+		}
+
+		if err := cache.UpdateCache(r.Context(), filter, fetchData); err != nil {
+			refreshErr := ErrorFromFetch("Error in refreshing tournament data", err)
+			refreshErr.LogError(logger)
+			refreshErr.JSONError(w)
+			return
+		}
+		logger.Info("Cache filter refreshed by admin", "filter", filter.Key())
+
+		// an empty entry is returned when there were no tournaments to cache
+		entry, _ := cache.EntryAt(filter)
+		entry.Date = date
+		entry.Filter = filter
+		json.NewEncoder(w).Encode(entry)
+	}
+}
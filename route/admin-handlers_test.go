@@ -0,0 +1,173 @@
+package route
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mockAdminToken = "mock admin token"
+
+func TestAdminCache(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	setup := func(t *testing.T) (http.Handler, *cache.Cache, *stubFetchData) {
+		mockFetchData := &stubFetchData{
+			tournaments: map[string]string{"1": "game1", "2": "game2"},
+		}
+		mockCache := cache.NewCache(5*time.Minute, 5*time.Hour, logger)
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		return RouterSetup(mockFetchData, mockCache, WithAdminToken(mockAdminToken)), mockCache, mockFetchData
+	}
+	adminRequest := func(method, target string) *http.Request {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer "+mockAdminToken)
+		return req
+	}
+
+	t.Run("It should reject requests without the admin token", func(t *testing.T) {
+		// Given
+		router, _, _ := setup(t)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/cache", nil)
+		req.Header.Set("Authorization", "Bearer wrong token")
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		// Then
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("It should not serve the admin endpoints without a configured token", func(t *testing.T) {
+		// Given
+		router := RouterSetup(&stubFetchData{}, cache.NewCache(5*time.Minute, 5*time.Hour, logger))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(http.MethodGet, "/api/v1/admin/cache"))
+		// Then
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("It should list the cached dates", func(t *testing.T) {
+		// Given
+		router, _, _ := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(http.MethodGet, "/api/v1/admin/cache"))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotEntries []cache.EntryInfo
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotEntries))
+		require.Len(t, gotEntries, 1)
+		assert.Equal(t, "2006-01-02", gotEntries[0].Date)
+		assert.Equal(t, 2, gotEntries[0].Tournaments)
+		assert.Equal(t, 0, gotEntries[0].AgeSeconds)
+	})
+
+	t.Run("It should invalidate a single date", func(t *testing.T) {
+		// Given
+		router, mockCache, _ := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(http.MethodDelete, "/api/v1/admin/cache/dates/2006-01-02"))
+		// Then
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.True(t, mockCache.IsCacheEmpty(models.DateFilter("2006-01-02")))
+	})
+
+	t.Run("It should reject an invalid date", func(t *testing.T) {
+		// Given
+		router, _, _ := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(http.MethodDelete, "/api/v1/admin/cache/dates/01-02-2006"))
+		// Then
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("It should invalidate a single tournament", func(t *testing.T) {
+		// Given
+		router, mockCache, _ := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(http.MethodDelete, "/api/v1/admin/cache/tournaments/1"))
+		// Then
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Len(t, mockCache.GetData(models.DateFilter("2006-01-02"), nil), 1)
+		assert.True(t, mockCache.ShouldUpdate(models.DateFilter("2006-01-02")))
+	})
+
+	t.Run("It should return not found for a tournament that is not cached", func(t *testing.T) {
+		// Given
+		router, _, _ := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(http.MethodDelete, "/api/v1/admin/cache/tournaments/3"))
+		// Then
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("It should refresh a date straight away", func(t *testing.T) {
+		// Given
+		router, mockCache, mockFetchData := setup(t)
+		mockFetchData.tournaments = map[string]string{"1": "game1", "2": "game2", "3": "game3"}
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(http.MethodPost, "/api/v1/admin/cache/dates/2006-01-02/refresh"))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotEntry cache.EntryInfo
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotEntry))
+		assert.Equal(t, "2006-01-02", gotEntry.Date)
+		assert.Equal(t, 3, gotEntry.Tournaments)
+		assert.Len(t, mockCache.GetData(models.DateFilter("2006-01-02"), nil), 3)
+	})
+
+	t.Run("It should refresh the filter given in the query", func(t *testing.T) {
+		// Given
+		router, mockCache, mockFetchData := setup(t)
+		givenFilter := models.TournamentFilter{CreatedAfter: "2006-01-02", CreatedBefore: "2006-01-05", State: models.TournamentStatePending}
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(http.MethodPost, "/api/v1/admin/cache/dates/2006-01-02/refresh?created_before=2006-01-05&tournament_state=pending"))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotEntry cache.EntryInfo
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotEntry))
+		assert.Equal(t, "2006-01-02", gotEntry.Date)
+		assert.Equal(t, givenFilter, gotEntry.Filter)
+		assert.Equal(t, 2, gotEntry.Tournaments)
+		assert.Equal(t, &givenFilter, mockFetchData.lastFilter.Load())
+		assert.Len(t, mockCache.GetData(givenFilter, nil), 2)
+	})
+
+	t.Run("It should reject an invalid filter to refresh", func(t *testing.T) {
+		// Given
+		router, _, mockFetchData := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(http.MethodPost, "/api/v1/admin/cache/dates/2006-01-02/refresh?tournament_state=underway"))
+		// Then
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, int32(1), mockFetchData.tournamentCalls.Load())
+	})
+
+	t.Run("It should clear the whole cache", func(t *testing.T) {
+		// Given
+		router, mockCache, _ := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(http.MethodDelete, "/api/v1/admin/cache"))
+		// Then
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.True(t, mockCache.IsCacheEmpty(models.DateFilter("2006-01-02")))
+	})
+}
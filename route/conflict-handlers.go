@@ -0,0 +1,203 @@
+package route
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/go-chi/httplog/v2"
+)
+
+// conflictMatchStates are the states of the matches fetched to find conflicts. Players are only called to open matches,
+// underway ones included, but the pending ones count towards MatchesAhead the same way as for player lookups
+var conflictMatchStates = playerMatchStates
+
+// GetConflicts lists the players called to more than one open match across the tournaments matching the request's
+// filter, so one of the matches can be held. The games query parameter keeps the conflicts involving one of those
+// games, and the state query parameter is ignored
+func GetConflicts(fetchData challongebracketmatches.FetchData, cache *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := httplog.LogEntry(r.Context())
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if cache.ShouldClearCacheData() {
+			cache.ClearCache()
+		}
+
+		requestValues, err := models.CreateRequestValues(r.URL.Query())
+		if err != nil {
+			requestQueryParamErr := ErrorBadRequest(err.Error(), err)
+			requestQueryParamErr.LogError(logger)
+			requestQueryParamErr.JSONError(w)
+			return
+		}
+
+		dataAge, stale, err := cache.EnsureData(r.Context(), requestValues.Filter, fetchData)
+		if err != nil {
+			cacheUpdateError := ErrorFromFetch("Error in getting tournament data", err)
+			cacheUpdateError.LogError(logger)
+			cacheUpdateError.JSONError(w)
+			return
+		}
+		w.Header().Set("X-Data-Age", strconv.Itoa(int(dataAge.Seconds())))
+
+		matches, fetchErrors := cache.GetMatchesConcurrently(r.Context(), cache.GetData(requestValues.Filter, requestValues.GameList), conflictMatchStates, fetchData)
+		if len(matches) == 0 && len(fetchErrors) > 0 {
+			getMatchesErr := ErrorFromFetch("Error in getting match data", fetchErrors[0])
+			getMatchesErr.LogError(logger)
+			getMatchesErr.JSONError(w)
+			return
+		}
+		fetchErrors = append(cache.GetErrors(requestValues.Filter, requestValues.GameList), fetchErrors...)
+
+		response := models.ConflictsResponse{
+			Conflicts: displayedConflicts(r.Context(), logger, requestValues.Filter, matches, fetchData, cache),
+			Errors:    tournamentErrors(logger, "Error in getting tournament data", fetchErrors),
+			Partial:   len(fetchErrors) > 0,
+			Stale:     stale,
+		}
+
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// displayedConflicts finds the conflicts across the open matches of every tournament of filter, whatever games are
+// displayed, and keeps the ones involving one of the displayed matches. A player called in a game that isn't displayed
+// still holds up the displayed match. Tournaments whose matches can't be fetched are left out
+func displayedConflicts(ctx context.Context, logger slog.Logger, filter models.TournamentFilter, displayed []models.TournamentMatches, fetchData challongebracketmatches.FetchData, cache *cache.Cache) []models.PlayerConflict {
+	tournaments := cache.GetData(filter, nil)
+	tournamentMatches, fetchErrors := cache.GetMatchesConcurrently(ctx, tournaments, conflictMatchStates, fetchData)
+	for _, fetchErr := range fetchErrors {
+		logger.Warn("Conflicts leave out a tournament whose matches could not be fetched", "tournament", fetchErr.TournamentID, "error", fetchErr.Err)
+	}
+
+	shown := map[[2]string]bool{}
+	for _, tournament := range displayed {
+		for _, match := range tournament.MatchList {
+			shown[[2]string{tournament.TournamentId, match.Id}] = true
+		}
+	}
+	conflicts := []models.PlayerConflict{}
+	for _, conflict := range findConflicts(tournaments, tournamentMatches) {
+		if slices.ContainsFunc(conflict.Matches, func(playerMatch models.PlayerMatch) bool {
+			return shown[[2]string{playerMatch.TournamentId, playerMatch.Match.Id}]
+		}) {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts
+}
+
+// playerEntry is a player found in one of the open matches, with the tag and Challonge account identifying them
+type playerEntry struct {
+	tag      string
+	username string
+	match    models.PlayerMatch
+}
+
+// findConflicts returns the players that are in more than one of the open matches of tournamentMatches, ordered by tag.
+// Entries are taken to be the same player when they share a tag, see models.NormalizeTag, or a Challonge account
+// from the rosters of tournaments. Challonge keeps participant names unique within a tournament, so a match's player
+// names are enough to find their account
+func findConflicts(tournaments []models.TournamentParticipants, tournamentMatches []models.TournamentMatches) []models.PlayerConflict {
+	usernames := map[string]map[string]string{}
+	for _, tournament := range tournaments {
+		usernames[tournament.TournamentID] = map[string]string{}
+		for participantId, username := range tournament.Usernames {
+			usernames[tournament.TournamentID][tournament.Participant[participantId]] = strings.ToLower(username)
+		}
+	}
+
+	var entries []playerEntry
+	for _, tournament := range tournamentMatches {
+		for _, match := range tournament.MatchList {
+			if match.State != models.MatchStateOpen {
+				continue
+			}
+			for _, players := range [][2]string{{match.Player1Name, match.Player2Name}, {match.Player2Name, match.Player1Name}} {
+				entry := playerEntry{
+					tag:      models.NormalizeTag(players[0]),
+					username: usernames[tournament.TournamentId][players[0]],
+					match: models.PlayerMatch{
+						GameName:     tournament.GameName,
+						TournamentId: tournament.TournamentId,
+						Player:       players[0],
+						Opponent:     players[1],
+						Match:        match,
+						MatchesAhead: matchesAhead(tournament.MatchList, match),
+					},
+				}
+				if players[0] == models.PlayerTBD || players[0] == models.PlayerBye || (entry.tag == "" && entry.username == "") {
+					continue
+				}
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	// link the entries sharing a tag or an account
+	roots := make([]int, len(entries))
+	var root func(int) int
+	root = func(i int) int {
+		if roots[i] != i {
+			roots[i] = root(roots[i])
+		}
+		return roots[i]
+	}
+	firstWithKey := map[string]int{}
+	for i, entry := range entries {
+		roots[i] = i
+		for _, key := range []string{"tag:" + entry.tag, "username:" + entry.username} {
+			if strings.HasSuffix(key, ":") {
+				continue
+			}
+			first, ok := firstWithKey[key]
+			if !ok {
+				firstWithKey[key] = i
+				continue
+			}
+			if a, b := root(first), root(i); a != b {
+				roots[max(a, b)] = min(a, b)
+			}
+		}
+	}
+
+	players := map[int][]playerEntry{}
+	for i, entry := range entries {
+		players[root(i)] = append(players[root(i)], entry)
+	}
+
+	conflicts := []models.PlayerConflict{}
+	for _, player := range players {
+		// the same tag on both sides of one match is two different participants
+		matchIds := map[[2]string]bool{}
+		for _, entry := range player {
+			matchIds[[2]string{entry.match.TournamentId, entry.match.Match.Id}] = true
+		}
+		if len(matchIds) < 2 {
+			continue
+		}
+		var conflict models.PlayerConflict
+		for _, entry := range player {
+			if conflict.Username == "" {
+				conflict.Username = entry.username
+			}
+			conflict.Matches = append(conflict.Matches, entry.match)
+		}
+		sortPlayerMatches(conflict.Matches)
+		conflict.Player = conflict.Matches[0].Player
+		conflict.Tag = models.NormalizeTag(conflict.Player)
+		conflicts = append(conflicts, conflict)
+	}
+	slices.SortFunc(conflicts, func(a, b models.PlayerConflict) int {
+		return cmp.Or(strings.Compare(a.Tag, b.Tag), strings.Compare(a.Player, b.Player))
+	})
+	return conflicts
+}
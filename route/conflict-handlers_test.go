@@ -0,0 +1,230 @@
+package route
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetConflicts(t *testing.T) {
+	setup := func(t *testing.T) (http.Handler, *stubFetchData) {
+		mockFetchData := &stubFetchData{
+			tournaments: map[string]string{"1": "game1", "2": "game2"},
+			participants: map[string]map[string]string{
+				"1": {"1": "TSM | Leffen", "2": "Hungrybox", "3": "Mango", "4": "Zain"},
+				"2": {"1": "leffen", "2": "Armada", "3": "Kingpin", "4": "Zain"},
+			},
+			usernames: map[string]map[string]string{
+				"1": {"3": "mang0"},
+				"2": {"3": "Mang0"},
+			},
+			matches: map[string][]models.Match{
+				"1": {
+					{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", SuggestedPlayOrder: 1, Station: "1"},
+					{Id: "11", State: models.MatchStateOpen, Player1Name: "Hungrybox", Player2Name: "TSM | Leffen", SuggestedPlayOrder: 2, Underway: true},
+					{Id: "12", State: models.MatchStatePending, Player1Name: "Zain", Player2Name: models.PlayerTBD, SuggestedPlayOrder: 3},
+				},
+				"2": {
+					{Id: "20", State: models.MatchStateOpen, Player1Name: "leffen", Player2Name: "Armada", SuggestedPlayOrder: 1},
+					{Id: "21", State: models.MatchStateOpen, Player1Name: "Kingpin", Player2Name: models.PlayerBye, SuggestedPlayOrder: 2},
+				},
+			},
+		}
+		mockCache := cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default())
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		return RouterSetup(mockFetchData, mockCache), mockFetchData
+	}
+
+	t.Run("It should list the players in more than one open match, matched by tag or account", func(t *testing.T) {
+		// Given
+		router, _ := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/conflicts?date=2006-01-02", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.ConflictsResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		assert.Equal(t, models.ConflictsResponse{
+			Conflicts: []models.PlayerConflict{
+				{
+					Player: "leffen", Tag: "leffen",
+					Matches: []models.PlayerMatch{
+						{
+							GameName: "game2", TournamentId: "2", Player: "leffen", Opponent: "Armada",
+							Match: models.Match{Id: "20", State: models.MatchStateOpen, Player1Name: "leffen", Player2Name: "Armada", SuggestedPlayOrder: 1},
+						},
+						{
+							GameName: "game1", TournamentId: "1", Player: "TSM | Leffen", Opponent: "Hungrybox",
+							Match:        models.Match{Id: "11", State: models.MatchStateOpen, Player1Name: "Hungrybox", Player2Name: "TSM | Leffen", SuggestedPlayOrder: 2, Underway: true},
+							MatchesAhead: 1,
+						},
+					},
+				},
+				{
+					Player: "Mango", Tag: "mango", Username: "mang0",
+					Matches: []models.PlayerMatch{
+						{
+							GameName: "game1", TournamentId: "1", Player: "Mango", Opponent: "Zain",
+							Match: models.Match{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", SuggestedPlayOrder: 1, Station: "1"},
+						},
+						{
+							GameName: "game2", TournamentId: "2", Player: "Kingpin", Opponent: models.PlayerBye,
+							Match:        models.Match{Id: "21", State: models.MatchStateOpen, Player1Name: "Kingpin", Player2Name: models.PlayerBye, SuggestedPlayOrder: 2},
+							MatchesAhead: 1,
+						},
+					},
+				},
+			},
+			Errors: []models.TournamentError{},
+		}, gotData)
+	})
+
+	t.Run("It should flag the conflicts in the matches response", func(t *testing.T) {
+		// Given
+		router, _ := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?date=2006-01-02&games=game1,game2", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.MatchesResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		require.Len(t, gotData.Conflicts, 2)
+		assert.Equal(t, "leffen", gotData.Conflicts[0].Tag)
+		assert.Equal(t, "mango", gotData.Conflicts[1].Tag)
+	})
+
+	t.Run("It should flag the players of the requested games that are called in another game", func(t *testing.T) {
+		// Given
+		router, mockFetchData := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/conflicts?date=2006-01-02&games=game1", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.ConflictsResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		require.Len(t, gotData.Conflicts, 2)
+		assert.Equal(t, "leffen", gotData.Conflicts[0].Tag)
+		assert.Equal(t, "game2", gotData.Conflicts[0].Matches[0].GameName)
+		assert.Equal(t, "mango", gotData.Conflicts[1].Tag)
+		assert.Equal(t, int32(2), mockFetchData.matchCalls.Load())
+	})
+
+	t.Run("It should count the pending matches ahead the same way as player lookups", func(t *testing.T) {
+		// Given
+		router, mockFetchData := setup(t)
+		mockFetchData.setMatches("2",
+			models.Match{Id: "19", State: models.MatchStatePending, Player1Name: models.PlayerTBD, Player2Name: "Plup", SuggestedPlayOrder: 1},
+			models.Match{Id: "20", State: models.MatchStateOpen, Player1Name: "leffen", Player2Name: "Armada", SuggestedPlayOrder: 2},
+		)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/conflicts?date=2006-01-02", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.ConflictsResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		require.Len(t, gotData.Conflicts, 1)
+		require.Len(t, gotData.Conflicts[0].Matches, 2)
+		for _, playerMatch := range gotData.Conflicts[0].Matches {
+			assert.Equal(t, 1, playerMatch.MatchesAhead)
+		}
+	})
+
+	t.Run("It should leave out the conflicts that don't involve a displayed match", func(t *testing.T) {
+		// Given
+		mockFetchData := &stubFetchData{
+			tournaments: map[string]string{"1": "game1", "2": "game2", "3": "game3"},
+			matches: map[string][]models.Match{
+				"1": {{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain"}},
+				"2": {{Id: "20", State: models.MatchStateOpen, Player1Name: "Armada", Player2Name: "Zain"}},
+				"3": {{Id: "30", State: models.MatchStateOpen, Player1Name: "Armada", Player2Name: "Plup"}},
+			},
+		}
+		mockCache := cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default())
+		router := RouterSetup(mockFetchData, mockCache)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?date=2006-01-02&games=game1", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.MatchesResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		require.Len(t, gotData.Tournaments, 1)
+		require.Len(t, gotData.Conflicts, 1)
+		assert.Equal(t, "zain", gotData.Conflicts[0].Tag)
+	})
+}
+
+func TestFindConflicts(t *testing.T) {
+	tt := []struct {
+		testName          string
+		tournaments       []models.TournamentParticipants
+		tournamentMatches []models.TournamentMatches
+		wantTags          []string
+	}{
+		{
+			testName: "It should not flag a player with a single open match",
+			tournamentMatches: []models.TournamentMatches{
+				{TournamentId: "1", MatchList: []models.Match{{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain"}}},
+				{TournamentId: "2", MatchList: []models.Match{{Id: "20", State: models.MatchStatePending, Player1Name: "Mango", Player2Name: models.PlayerTBD}}},
+			},
+			wantTags: []string{},
+		},
+		{
+			testName: "It should not flag two participants with the same tag in one match",
+			tournamentMatches: []models.TournamentMatches{
+				{TournamentId: "1", MatchList: []models.Match{{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "C9 | mango"}}},
+			},
+			wantTags: []string{},
+		},
+		{
+			testName: "It should not link undecided players",
+			tournamentMatches: []models.TournamentMatches{
+				{TournamentId: "1", MatchList: []models.Match{{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: models.PlayerBye}}},
+				{TournamentId: "2", MatchList: []models.Match{{Id: "20", State: models.MatchStateOpen, Player1Name: "Zain", Player2Name: models.PlayerBye}}},
+			},
+			wantTags: []string{},
+		},
+		{
+			testName: "It should link entries sharing an account whatever their tags",
+			tournaments: []models.TournamentParticipants{
+				{TournamentID: "2", Participant: map[string]string{"1": "Kingpin"}, Usernames: map[string]string{"1": "mang0"}},
+				{TournamentID: "3", Participant: map[string]string{"1": "The Kid"}, Usernames: map[string]string{"1": "MANG0"}},
+			},
+			tournamentMatches: []models.TournamentMatches{
+				{TournamentId: "1", MatchList: []models.Match{{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain"}}},
+				{TournamentId: "2", MatchList: []models.Match{{Id: "20", State: models.MatchStateOpen, Player1Name: "Kingpin", Player2Name: "Armada"}}},
+				{TournamentId: "3", MatchList: []models.Match{
+					{Id: "30", State: models.MatchStateOpen, Player1Name: "The Kid", Player2Name: "Plup"},
+					{Id: "31", State: models.MatchStateOpen, Player1Name: "mango", Player2Name: "Hax"},
+				}},
+			},
+			wantTags: []string{"kingpin", "mango"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When
+			gotConflicts := findConflicts(tc.tournaments, tc.tournamentMatches)
+			// Then
+			gotTags := []string{}
+			for _, conflict := range gotConflicts {
+				gotTags = append(gotTags, conflict.Tag)
+			}
+			assert.Equal(t, tc.wantTags, gotTags)
+		})
+	}
+}
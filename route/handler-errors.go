@@ -1,17 +1,29 @@
 package route
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
 	"runtime"
+	"strconv"
+	"time"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/models"
 )
 
+// retry delay suggested to clients when Challonge rate limits us without saying for how long
+const defaultRetryAfter = 30 * time.Second
+
 type StatusError struct {
-	Code   int
-	Msg    string
-	ErrLog string
+	Code       int
+	Msg        string
+	ErrLog     string
+	RetryAfter time.Duration
 }
 
 func newError(msg string, err error, code int) StatusError {
@@ -28,6 +40,10 @@ func ErrorBadRequest(msg string, err error) StatusError {
 	return newError(msg, err, http.StatusBadRequest)
 }
 
+func ErrorUnauthorized(msg string, err error) StatusError {
+	return newError(msg, err, http.StatusUnauthorized)
+}
+
 func ErrorNotFound(msg string, err error) StatusError {
 	return newError(msg, err, http.StatusNotFound)
 }
@@ -36,12 +52,62 @@ func ErrorInternal(msg string, err error) StatusError {
 	return newError(msg, err, http.StatusInternalServerError)
 }
 
+// ErrorFromFetch maps errors returned by challongebracketmatches.FetchData to a matching response,
+// falling back to an internal error with msg
+func ErrorFromFetch(msg string, err error) StatusError {
+	var apiErr *challongebracketmatches.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+			return newError("Challonge rejected the API key, check the API_KEY configured for this server", err, http.StatusBadGateway)
+		case apiErr.StatusCode == http.StatusNotFound && apiErr.TournamentID != "":
+			return newError(fmt.Sprintf("Tournament %s was not found on Challonge, it may have been deleted", apiErr.TournamentID), err, http.StatusNotFound)
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			statusErr := newError("Challonge rate limit reached, try again later", err, http.StatusServiceUnavailable)
+			statusErr.RetryAfter = apiErr.RetryAfter
+			if statusErr.RetryAfter <= 0 {
+				statusErr.RetryAfter = defaultRetryAfter
+			}
+			return statusErr
+		case apiErr.StatusCode == http.StatusServiceUnavailable && apiErr.RetryAfter > 0:
+			statusErr := newError("Challonge is currently unavailable, try again later", err, http.StatusServiceUnavailable)
+			statusErr.RetryAfter = apiErr.RetryAfter
+			return statusErr
+		case apiErr.StatusCode >= http.StatusInternalServerError:
+			return newError("Challonge is currently unavailable", err, http.StatusBadGateway)
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		return newError("Timed out waiting for Challonge", err, http.StatusGatewayTimeout)
+	}
+	return newError(msg, err, http.StatusInternalServerError)
+}
+
+// tournamentErrors logs the error of every tournament that could not be fetched and describes each of them for a
+// response, using msg for the errors ErrorFromFetch does not recognise
+func tournamentErrors(logger slog.Logger, msg string, fetchErrors []challongebracketmatches.TournamentError) []models.TournamentError {
+	errs := []models.TournamentError{}
+	for _, fetchErr := range fetchErrors {
+		tournamentErr := ErrorFromFetch(msg, fetchErr)
+		tournamentErr.LogError(logger)
+		errs = append(errs, models.TournamentError{
+			TournamentId: fetchErr.TournamentID,
+			GameName:     fetchErr.GameName,
+			Message:      tournamentErr.Msg,
+		})
+	}
+	return errs
+}
+
 func (sc StatusError) LogError(logger slog.Logger) {
 	logger.Error(sc.Msg, "error", sc.ErrLog)
 }
 
 func (sc StatusError) JSONError(w http.ResponseWriter) {
 	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if sc.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(sc.RetryAfter.Seconds()))))
+	}
 	w.WriteHeader(sc.Code)
 	errResp := struct {
 		Message string
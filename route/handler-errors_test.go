@@ -0,0 +1,117 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorFromFetch(t *testing.T) {
+	// Given
+	tt := []struct {
+		testName       string
+		err            error
+		wantCode       int
+		wantMsg        string
+		wantRetryAfter string
+	}{
+		{
+			testName: "bad api key",
+			err:      &challongebracketmatches.APIError{StatusCode: http.StatusUnauthorized, Endpoint: "/tournaments.json"},
+			wantCode: http.StatusBadGateway,
+			wantMsg:  "Challonge rejected the API key, check the API_KEY configured for this server",
+		},
+		{
+			testName: "tournament deleted",
+			err:      fmt.Errorf("fetching matches: %w", &challongebracketmatches.APIError{StatusCode: http.StatusNotFound, TournamentID: "1234"}),
+			wantCode: http.StatusNotFound,
+			wantMsg:  "Tournament 1234 was not found on Challonge, it may have been deleted",
+		},
+		{
+			testName:       "rate limited with retry after",
+			err:            &challongebracketmatches.APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 1500 * time.Millisecond},
+			wantCode:       http.StatusServiceUnavailable,
+			wantMsg:        "Challonge rate limit reached, try again later",
+			wantRetryAfter: "2",
+		},
+		{
+			testName:       "rate limited without retry after",
+			err:            &challongebracketmatches.APIError{StatusCode: http.StatusTooManyRequests},
+			wantCode:       http.StatusServiceUnavailable,
+			wantMsg:        "Challonge rate limit reached, try again later",
+			wantRetryAfter: "30",
+		},
+		{
+			testName: "challonge down",
+			err:      &challongebracketmatches.APIError{StatusCode: http.StatusBadGateway},
+			wantCode: http.StatusBadGateway,
+			wantMsg:  "Challonge is currently unavailable",
+		},
+		{
+			testName:       "challonge down with retry after",
+			err:            &challongebracketmatches.APIError{StatusCode: http.StatusServiceUnavailable, RetryAfter: time.Minute},
+			wantCode:       http.StatusServiceUnavailable,
+			wantMsg:        "Challonge is currently unavailable, try again later",
+			wantRetryAfter: "60",
+		},
+		{
+			testName: "timeout",
+			err:      fmt.Errorf("get: %w", context.DeadlineExceeded),
+			wantCode: http.StatusGatewayTimeout,
+			wantMsg:  "Timed out waiting for Challonge",
+		},
+		{
+			testName: "unknown error",
+			err:      errors.New("boom"),
+			wantCode: http.StatusInternalServerError,
+			wantMsg:  "Error in getting match data",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testName, func(t *testing.T) {
+			// When
+			gotErr := ErrorFromFetch("Error in getting match data", tc.err)
+			w := httptest.NewRecorder()
+			gotErr.JSONError(w)
+			// Then
+			assert.Equal(t, tc.wantCode, gotErr.Code)
+			assert.Equal(t, tc.wantMsg, gotErr.Msg)
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantRetryAfter, w.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestTournamentErrors(t *testing.T) {
+	t.Run("It should describe every tournament that failed", func(t *testing.T) {
+		// Given
+		fetchErrors := []challongebracketmatches.TournamentError{
+			{TournamentID: "1", GameName: "game1", Err: &challongebracketmatches.APIError{StatusCode: http.StatusNotFound, TournamentID: "1"}},
+			{TournamentID: "2", GameName: "game2", Err: errors.New("boom")},
+		}
+		// When
+		gotErrors := tournamentErrors(*slog.Default(), "Error in getting match data", fetchErrors)
+		// Then
+		assert.Equal(t, []models.TournamentError{
+			{TournamentId: "1", GameName: "game1", Message: "Tournament 1 was not found on Challonge, it may have been deleted"},
+			{TournamentId: "2", GameName: "game2", Message: "Error in getting match data"},
+		}, gotErrors)
+	})
+
+	t.Run("It should return an empty list when nothing failed", func(t *testing.T) {
+		// When
+		gotErrors := tournamentErrors(*slog.Default(), "Error in getting match data", nil)
+		// Then
+		assert.Equal(t, []models.TournamentError{}, gotErrors)
+	})
+}
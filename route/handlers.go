@@ -3,8 +3,7 @@ package route
 import (
 	"encoding/json"
 	"net/http"
-	"sort"
-	"sync"
+	"strconv"
 
 	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
 	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
@@ -12,6 +11,23 @@ import (
 	"github.com/go-chi/httplog/v2"
 )
 
+// healthResponse reports the server is up alongside how well the match cache is absorbing requests
+type healthResponse struct {
+	Status     string                `json:"status"`
+	MatchCache cache.MatchCacheStats `json:"match_cache"`
+}
+
+func GetHealth(cache *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(healthResponse{
+			Status:     "UP",
+			MatchCache: cache.MatchStats(),
+		})
+	}
+}
+
 func GetMatches(fetchData challongebracketmatches.FetchData, cache *cache.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// get logger
@@ -34,83 +50,71 @@ func GetMatches(fetchData challongebracketmatches.FetchData, cache *cache.Cache)
 
 		// Get tournaments and participants
 		var tournamentsAndParticipants []models.TournamentParticipants
-		// check if cache is empty or time limit has been exceeded
-		if cache.IsCacheEmptyAtDate(requestValues.Date) || cache.ShouldUpdate(requestValues.Date) {
-			// update cache
-			err := cache.UpdateCache(requestValues.Date, fetchData)
-			if err != nil {
-				cacheUpdateError := ErrorInternal("Error in getting tournament data", err)
-				cacheUpdateError.LogError(logger)
-				cacheUpdateError.JSONError(w)
-				return
-			}
+		// refreshes the cache if it is empty or its data is too old to serve
+		dataAge, stale, err := cache.EnsureData(r.Context(), requestValues.Filter, fetchData)
+		if err != nil {
+			cacheUpdateError := ErrorFromFetch("Error in getting tournament data", err)
+			cacheUpdateError.LogError(logger)
+			cacheUpdateError.JSONError(w)
+			return
 		}
+		w.Header().Set("X-Data-Age", strconv.Itoa(int(dataAge.Seconds())))
 
-		tournamentsAndParticipants = cache.GetData(requestValues.Date, requestValues.GameList)
+		tournamentsAndParticipants = cache.GetData(requestValues.Filter, requestValues.GameList)
 
-		matches, err := getMatchesConcurrently(tournamentsAndParticipants, fetchData)
-		if err != nil {
-			getMatchesErr := ErrorInternal("Error in getting match data", err)
+		matches, fetchErrors := cache.GetMatchesConcurrently(r.Context(), tournamentsAndParticipants, requestValues.States, fetchData)
+		if len(matches) == 0 && len(fetchErrors) > 0 {
+			getMatchesErr := ErrorFromFetch("Error in getting match data", fetchErrors[0])
 			getMatchesErr.LogError(logger)
 			getMatchesErr.JSONError(w)
 			return
 		}
+		fetchErrors = append(cache.GetErrors(requestValues.Filter, requestValues.GameList), fetchErrors...)
+
+		response := models.MatchesResponse{
+			Tournaments: matches,
+			Conflicts:   displayedConflicts(r.Context(), logger, requestValues.Filter, matches, fetchData, cache),
+			Errors:      tournamentErrors(logger, "Error in getting tournament data", fetchErrors),
+			Partial:     len(fetchErrors) > 0,
+			Stale:       stale,
+		}
 
-		json.NewEncoder(w).Encode(matches)
+		json.NewEncoder(w).Encode(response)
 	}
 }
 
-func getMatchesConcurrently(tournamentsAndParticipants []models.TournamentParticipants, fetchData challongebracketmatches.FetchData) ([]models.TournamentMatches, error) {
-	matches := []models.TournamentMatches{}
-
-	chanResponse := make(chan struct {
-		tournamentMatches *models.TournamentMatches
-		err               error
-	})
-	var wg sync.WaitGroup
-	for _, elem := range tournamentsAndParticipants {
-		wg.Add(1)
-		go func(tournament models.TournamentParticipants, chanResponse chan struct {
-			tournamentMatches *models.TournamentMatches
-			err               error
-		}) {
-			defer wg.Done()
-			match, err := fetchData.FetchMatches(tournament)
-			if err != nil {
-				chanResponse <- struct {
-					tournamentMatches *models.TournamentMatches
-					err               error
-				}{
-					tournamentMatches: nil,
-					err:               err,
-				}
-				return
-			}
-			chanResponse <- struct {
-				tournamentMatches *models.TournamentMatches
-				err               error
-			}{
-				tournamentMatches: &match,
-				err:               nil,
-			}
-		}(elem, chanResponse)
-	}
+// GetTournaments lists the tournaments matching the request's filter with their bracket details, without fetching their matches
+func GetTournaments(fetchData challongebracketmatches.FetchData, cache *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := httplog.LogEntry(r.Context())
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if cache.ShouldClearCacheData() {
+			cache.ClearCache()
+		}
 
-	go func() {
-		wg.Wait()
-		close(chanResponse)
-	}()
+		requestValues, err := models.CreateRequestValues(r.URL.Query())
+		if err != nil {
+			requestQueryParamErr := ErrorBadRequest(err.Error(), err)
+			requestQueryParamErr.LogError(logger)
+			requestQueryParamErr.JSONError(w)
+			return
+		}
 
-	for getMatchesResult := range chanResponse {
-		if getMatchesResult.err != nil {
-			return nil, getMatchesResult.err
+		dataAge, stale, err := cache.EnsureData(r.Context(), requestValues.Filter, fetchData)
+		if err != nil {
+			cacheUpdateError := ErrorFromFetch("Error in getting tournament data", err)
+			cacheUpdateError.LogError(logger)
+			cacheUpdateError.JSONError(w)
+			return
 		}
-		matches = append(matches, *getMatchesResult.tournamentMatches)
-	}
+		w.Header().Set("X-Data-Age", strconv.Itoa(int(dataAge.Seconds())))
 
-	sort.Slice(matches, func(i, j int) bool {
-		return matches[i].GameName < matches[j].GameName
-	})
+		response := models.TournamentsResponse{
+			Tournaments: cache.GetTournaments(requestValues.Filter, requestValues.GameList),
+			Errors:      tournamentErrors(logger, "Error in getting tournament data", cache.GetErrors(requestValues.Filter, requestValues.GameList)),
+			Stale:       stale,
+		}
 
-	return matches, nil
+		json.NewEncoder(w).Encode(response)
+	}
 }
@@ -0,0 +1,383 @@
+package route
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMatches(t *testing.T) {
+	t.Run("It should return the tournaments that succeeded alongside the ones that failed", func(t *testing.T) {
+		// Given
+		mockFetchData := &stubFetchData{
+			tournaments: map[string]string{"1": "game1", "2": "game2"},
+			matches: map[string][]models.Match{
+				"1": {{Id: "10", Player1Name: "testName1", Player2Name: "testName2"}},
+			},
+			failingMatches: map[string]error{
+				"2": &challongebracketmatches.APIError{StatusCode: http.StatusNotFound, TournamentID: "2"},
+			},
+		}
+		router := RouterSetup(mockFetchData, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?date=2006-01-02", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.MatchesResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		assert.Equal(t, models.MatchesResponse{
+			Tournaments: []models.TournamentMatches{
+				{
+					GameName:     "game1",
+					TournamentId: "1",
+					MatchList:    []models.Match{{Id: "10", Player1Name: "testName1", Player2Name: "testName2"}},
+				},
+			},
+			Conflicts: []models.PlayerConflict{},
+			Errors: []models.TournamentError{
+				{TournamentId: "2", GameName: "game2", Message: "Tournament 2 was not found on Challonge, it may have been deleted"},
+			},
+			Partial: true,
+		}, gotData)
+	})
+
+	t.Run("It should return an error when every tournament failed", func(t *testing.T) {
+		// Given
+		mockFetchData := &stubFetchData{
+			tournaments: map[string]string{"2": "game2"},
+			failingMatches: map[string]error{
+				"2": &challongebracketmatches.APIError{StatusCode: http.StatusNotFound, TournamentID: "2"},
+			},
+		}
+		router := RouterSetup(mockFetchData, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?date=2006-01-02", nil))
+		// Then
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("It should report the age of the data it served", func(t *testing.T) {
+		// Given
+		mockFetchData := &stubFetchData{
+			tournaments: map[string]string{"1": "game1"},
+		}
+		mockCache := cache.NewCache(time.Nanosecond, 5*time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)), cache.WithMaxStaleness(time.Hour))
+		router := RouterSetup(mockFetchData, mockCache)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?date=2006-01-02", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		// When
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?date=2006-01-02", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "0", w.Header().Get("X-Data-Age"))
+		var gotData models.MatchesResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		assert.True(t, gotData.Stale)
+	})
+
+	t.Run("It should only return matches in the requested states", func(t *testing.T) {
+		// Given
+		mockFetchData := &stubFetchData{
+			tournaments: map[string]string{"1": "game1"},
+			matches: map[string][]models.Match{
+				"1": {
+					{Id: "10", State: models.MatchStateOpen},
+					{Id: "11", State: models.MatchStatePending},
+					{Id: "12", State: models.MatchStateComplete},
+				},
+			},
+		}
+		router := RouterSetup(mockFetchData, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?date=2006-01-02&state=pending,complete", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.MatchesResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		require.Len(t, gotData.Tournaments, 1)
+		assert.Equal(t, []models.Match{
+			{Id: "11", State: models.MatchStatePending},
+			{Id: "12", State: models.MatchStateComplete},
+		}, gotData.Tournaments[0].MatchList)
+	})
+
+	t.Run("It should reject an unknown match state", func(t *testing.T) {
+		// Given
+		router := RouterSetup(&stubFetchData{}, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?date=2006-01-02&state=underway", nil))
+		// Then
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("It should reject a date window ending before it starts", func(t *testing.T) {
+		// Given
+		router := RouterSetup(&stubFetchData{}, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?created_after=2006-01-02&created_before=2006-01-01", nil))
+		// Then
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("It should not be partial when everything succeeded", func(t *testing.T) {
+		// Given
+		mockFetchData := &stubFetchData{
+			tournaments: map[string]string{"1": "game1"},
+		}
+		router := RouterSetup(mockFetchData, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?date=2006-01-02", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.MatchesResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		assert.False(t, gotData.Partial)
+		assert.False(t, gotData.Stale)
+		assert.Equal(t, "0", w.Header().Get("X-Data-Age"))
+		assert.Empty(t, gotData.Errors)
+		assert.Len(t, gotData.Tournaments, 1)
+	})
+}
+
+func TestGetMatchesCached(t *testing.T) {
+	// Given
+	mockFetchData := &stubFetchData{
+		tournaments: map[string]string{"1": "game1", "2": "game2"},
+	}
+	router := RouterSetup(mockFetchData, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default(), cache.WithMatchTTL(time.Hour)))
+	// When
+	for _, query := range []string{"", "&games=game1", "&games=game2"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?date=2006-01-02"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	// Then
+	// the open matches of both tournaments are fetched once, and so are their open and pending matches used to find conflicts
+	assert.Equal(t, int32(4), mockFetchData.matchCalls.Load())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "UP", "match_cache": {"hits": 6, "misses": 4, "participant_misses": 0}}`, w.Body.String())
+}
+
+// run with -race to catch unsynchronised access to the cache
+func TestGetMatchesConcurrentRequests(t *testing.T) {
+	// Given
+	mockFetchData := &stubFetchData{
+		tournaments: map[string]string{"1": "game1", "2": "game2", "3": "game3"},
+		matches: map[string][]models.Match{
+			"1": {{Id: "10", Player1Name: "testName1", Player2Name: "testName2"}},
+		},
+	}
+	// expire the cache constantly so requests race on updates as well as reads
+	router := RouterSetup(mockFetchData, cache.NewCache(time.Nanosecond, time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	// When
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?date=2006-01-02&games=game1,game2", nil))
+			// Then
+			assert.Equal(t, http.StatusOK, w.Code)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestGetMatchesSharedRefresh(t *testing.T) {
+	// Given
+	mockFetchData := &stubFetchData{
+		tournaments: map[string]string{"1": "game1", "2": "game2"},
+	}
+	router := RouterSetup(mockFetchData, cache.NewCache(5*time.Minute, 5*time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	// When
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches?date=2006-01-02", nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	// Then
+	// requests that found the cache empty just before the first refresh finished use its data rather than fetching again
+	assert.Equal(t, int32(1), mockFetchData.tournamentCalls.Load())
+}
+
+func TestGetTournaments(t *testing.T) {
+	t.Run("It should list the tournaments matching the filter", func(t *testing.T) {
+		// Given
+		mockFetchData := &stubFetchData{
+			tournaments:  map[string]string{"2": "game2", "1": "game1"},
+			participants: map[string]map[string]string{"1": {"1": "testName1", "2": "testName2"}},
+		}
+		router := RouterSetup(mockFetchData, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tournaments?created_after=2006-01-01&created_before=2006-01-03&tournament_state=pending", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.TournamentsResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		assert.Equal(t, models.TournamentsResponse{
+			Tournaments: []models.TournamentSummary{
+				{Id: "1", GameName: "game1", Participants: 2},
+				{Id: "2", GameName: "game2", Participants: 0},
+			},
+			Errors: []models.TournamentError{},
+		}, gotData)
+		assert.Equal(t, &models.TournamentFilter{
+			CreatedAfter:  "2006-01-01",
+			CreatedBefore: "2006-01-03",
+			State:         models.TournamentStatePending,
+		}, mockFetchData.lastFilter.Load())
+		assert.Zero(t, mockFetchData.matchCalls.Load())
+	})
+
+	t.Run("It should return the bracket details of the tournaments of the requested games", func(t *testing.T) {
+		// Given
+		startsAt := time.Date(2006, 1, 2, 18, 0, 0, 0, time.UTC)
+		givenSummary := models.TournamentSummary{
+			Id:               "1",
+			Name:             "Weekly 12",
+			URL:              "weekly_12",
+			GameName:         "game1",
+			TournamentType:   "double elimination",
+			State:            models.TournamentStateInProgress,
+			Participants:     16,
+			StartsAt:         &startsAt,
+			FullChallongeURL: "https://challonge.com/weekly_12",
+		}
+		mockFetchData := &stubFetchData{
+			tournaments: map[string]string{"2": "game2"},
+			summaries:   map[string]models.TournamentSummary{"1": givenSummary},
+		}
+		router := RouterSetup(mockFetchData, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tournaments?date=2006-01-02&games=game1", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.TournamentsResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		assert.Equal(t, []models.TournamentSummary{givenSummary}, gotData.Tournaments)
+	})
+
+	t.Run("It should reject an unknown tournament state", func(t *testing.T) {
+		// Given
+		router := RouterSetup(&stubFetchData{}, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tournaments?date=2006-01-02&tournament_state=underway", nil))
+		// Then
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// stubFetchData serves tournaments, participants and matches from memory, leaving out matches in states that were not requested
+type stubFetchData struct {
+	tournaments     map[string]string
+	summaries       map[string]models.TournamentSummary // replace the bare summaries made up from tournaments
+	participants    map[string]map[string]string
+	usernames       map[string]map[string]string
+	failingRosters  map[string]error
+	failingMatches  map[string]error
+	tournamentCalls atomic.Int32
+	matchCalls      atomic.Int32
+	lastFilter      atomic.Pointer[models.TournamentFilter]
+	// matchesMu guards matches, which setMatches changes while they may be fetched
+	matchesMu sync.Mutex
+	matches   map[string][]models.Match
+}
+
+func (s *stubFetchData) setMatches(tournamentId string, matches ...models.Match) {
+	s.matchesMu.Lock()
+	defer s.matchesMu.Unlock()
+
+	s.matches[tournamentId] = matches
+}
+
+func (s *stubFetchData) FetchTournaments(ctx context.Context, filter models.TournamentFilter) (map[string]models.TournamentSummary, error) {
+	s.tournamentCalls.Add(1)
+	s.lastFilter.Store(&filter)
+	ret := map[string]models.TournamentSummary{}
+	for tournamentId, tournamentGame := range s.tournaments {
+		ret[tournamentId] = models.TournamentSummary{Id: tournamentId, GameName: tournamentGame}
+	}
+	maps.Copy(ret, s.summaries)
+	return ret, nil
+}
+
+func (s *stubFetchData) FetchTournament(ctx context.Context, tournamentId string) (models.TournamentSummary, error) {
+	if summary, ok := s.summaries[tournamentId]; ok {
+		return summary, nil
+	}
+	tournamentGame, ok := s.tournaments[tournamentId]
+	if !ok {
+		return models.TournamentSummary{}, &challongebracketmatches.APIError{StatusCode: http.StatusNotFound, TournamentID: tournamentId}
+	}
+	return models.TournamentSummary{Id: tournamentId, GameName: tournamentGame}, nil
+}
+
+func (s *stubFetchData) FetchParticipants(ctx context.Context, tournamentId, tournamentGame string) (models.TournamentParticipants, error) {
+	if err, ok := s.failingRosters[tournamentId]; ok {
+		return models.TournamentParticipants{}, err
+	}
+	return models.TournamentParticipants{
+		GameName:     tournamentGame,
+		TournamentID: tournamentId,
+		Participant:  s.participants[tournamentId],
+		Usernames:    s.usernames[tournamentId],
+	}, nil
+}
+
+func (s *stubFetchData) FetchMatches(ctx context.Context, tournamentParticipants models.TournamentParticipants, states []models.MatchState) (models.TournamentMatches, error) {
+	s.matchCalls.Add(1)
+	if err, ok := s.failingMatches[tournamentParticipants.TournamentID]; ok {
+		return models.TournamentMatches{}, err
+	}
+	s.matchesMu.Lock()
+	defer s.matchesMu.Unlock()
+	matchList := []models.Match{}
+	for _, match := range s.matches[tournamentParticipants.TournamentID] {
+		if match.State == "" || slices.Contains(states, match.State) {
+			matchList = append(matchList, match)
+		}
+	}
+	return models.TournamentMatches{
+		GameName:     tournamentParticipants.GameName,
+		TournamentId: tournamentParticipants.TournamentID,
+		MatchList:    matchList,
+	}, nil
+}
@@ -0,0 +1,137 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httplog/v2"
+)
+
+var errPlayerNameEmpty = errors.New("player name must contain letters or digits")
+
+// playerMatchStates are the states of the matches a player still has to play, sorted like parsed states
+var playerMatchStates = []models.MatchState{models.MatchStateOpen, models.MatchStatePending}
+
+// GetPlayerMatches returns the open and pending matches of a player in every cached tournament. The name is compared
+// on its tag, see models.NormalizeTag, and falls back to partial tags when no participant has the exact tag
+func GetPlayerMatches(fetchData challongebracketmatches.FetchData, cache *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := httplog.LogEntry(r.Context())
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if cache.ShouldClearCacheData() {
+			cache.ClearCache()
+		}
+
+		name := chi.URLParam(r, "name")
+		// chi routes on the raw path when the request escaped more than needed
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+		tag := models.NormalizeTag(name)
+		if tag == "" {
+			nameErr := ErrorBadRequest(errPlayerNameEmpty.Error(), errPlayerNameEmpty)
+			nameErr.LogError(logger)
+			nameErr.JSONError(w)
+			return
+		}
+
+		tournaments, players := findPlayer(cache.CachedTournaments(), tag)
+		matches, fetchErrors := cache.GetMatchesConcurrently(r.Context(), tournaments, playerMatchStates, fetchData)
+		if len(matches) == 0 && len(fetchErrors) > 0 {
+			getMatchesErr := ErrorFromFetch("Error in getting match data", fetchErrors[0])
+			getMatchesErr.LogError(logger)
+			getMatchesErr.JSONError(w)
+			return
+		}
+
+		response := models.PlayerMatchesResponse{
+			Matches: playerMatches(matches, players),
+			Errors:  tournamentErrors(logger, "Error in getting match data", fetchErrors),
+			Partial: len(fetchErrors) > 0,
+		}
+
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// findPlayer returns the tournaments with a participant whose tag is tag, alongside the names those participants are
+// registered under in each tournament. When there are none, participants whose tag contains tag are returned instead
+func findPlayer(tournaments []models.TournamentParticipants, tag string) ([]models.TournamentParticipants, map[string][]string) {
+	for _, matchTag := range []func(string) bool{
+		func(participantTag string) bool { return participantTag == tag },
+		func(participantTag string) bool { return strings.Contains(participantTag, tag) },
+	} {
+		var found []models.TournamentParticipants
+		players := map[string][]string{}
+		for _, tournament := range tournaments {
+			for _, participant := range tournament.Participant {
+				if matchTag(models.NormalizeTag(participant)) {
+					players[tournament.TournamentID] = append(players[tournament.TournamentID], participant)
+				}
+			}
+			if len(players[tournament.TournamentID]) > 0 {
+				found = append(found, tournament)
+			}
+		}
+		if len(found) > 0 {
+			return found, players
+		}
+	}
+	return nil, nil
+}
+
+// playerMatches picks the matches of players out of every tournament's match list, soonest to be played first
+func playerMatches(tournamentMatches []models.TournamentMatches, players map[string][]string) []models.PlayerMatch {
+	ret := []models.PlayerMatch{}
+	for _, tournament := range tournamentMatches {
+		names := players[tournament.TournamentId]
+		for _, match := range tournament.MatchList {
+			playerMatch := models.PlayerMatch{
+				GameName:     tournament.GameName,
+				TournamentId: tournament.TournamentId,
+				Match:        match,
+			}
+			switch {
+			case slices.Contains(names, match.Player1Name):
+				playerMatch.Player, playerMatch.Opponent = match.Player1Name, match.Player2Name
+			case slices.Contains(names, match.Player2Name):
+				playerMatch.Player, playerMatch.Opponent = match.Player2Name, match.Player1Name
+			default:
+				continue
+			}
+			playerMatch.MatchesAhead = matchesAhead(tournament.MatchList, match)
+			ret = append(ret, playerMatch)
+		}
+	}
+	sortPlayerMatches(ret)
+	return ret
+}
+
+// matchesAhead counts the matches of matchList still to be played that are suggested to be played before match
+func matchesAhead(matchList []models.Match, match models.Match) int {
+	ahead := 0
+	for _, other := range matchList {
+		if other.State != models.MatchStateComplete && other.SuggestedPlayOrder < match.SuggestedPlayOrder {
+			ahead++
+		}
+	}
+	return ahead
+}
+
+// sortPlayerMatches orders player matches soonest to be played first, then by game
+func sortPlayerMatches(playerMatches []models.PlayerMatch) {
+	slices.SortStableFunc(playerMatches, func(a, b models.PlayerMatch) int {
+		if a.MatchesAhead != b.MatchesAhead {
+			return a.MatchesAhead - b.MatchesAhead
+		}
+		return strings.Compare(a.GameName, b.GameName)
+	})
+}
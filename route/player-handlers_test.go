@@ -0,0 +1,129 @@
+package route
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPlayerMatches(t *testing.T) {
+	setup := func(t *testing.T) (http.Handler, *stubFetchData) {
+		mockFetchData := &stubFetchData{
+			tournaments: map[string]string{"1": "game1", "2": "game2", "3": "game3"},
+			participants: map[string]map[string]string{
+				"1": {"1": "TSM | Leffen", "2": "Hungrybox", "3": "Mango", "4": "Zain"},
+				"2": {"1": "leffen", "2": "Armada"},
+				"3": {"1": "Leffen2", "2": "Plup"},
+			},
+			matches: map[string][]models.Match{
+				"1": {
+					{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", SuggestedPlayOrder: 1, Station: "1"},
+					{Id: "11", State: models.MatchStateOpen, Player1Name: "Hungrybox", Player2Name: "TSM | Leffen", SuggestedPlayOrder: 2, Station: "2"},
+					{Id: "12", State: models.MatchStatePending, Player1Name: "TSM | Leffen", Player2Name: models.PlayerTBD, SuggestedPlayOrder: 3},
+					{Id: "13", State: models.MatchStateComplete, Player1Name: "TSM | Leffen", Player2Name: "Mango", SuggestedPlayOrder: 0},
+				},
+				"2": {
+					{Id: "20", State: models.MatchStateOpen, Player1Name: "leffen", Player2Name: "Armada", SuggestedPlayOrder: 1},
+				},
+			},
+		}
+		mockCache := cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default())
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		return RouterSetup(mockFetchData, mockCache), mockFetchData
+	}
+
+	t.Run("It should find the open and pending matches of a player in every game, soonest first", func(t *testing.T) {
+		// Given
+		router, mockFetchData := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/players/LEFFEN/matches", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.PlayerMatchesResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		assert.Equal(t, models.PlayerMatchesResponse{
+			Matches: []models.PlayerMatch{
+				{
+					GameName: "game2", TournamentId: "2", Player: "leffen", Opponent: "Armada",
+					Match:        models.Match{Id: "20", State: models.MatchStateOpen, Player1Name: "leffen", Player2Name: "Armada", SuggestedPlayOrder: 1},
+					MatchesAhead: 0,
+				},
+				{
+					GameName: "game1", TournamentId: "1", Player: "TSM | Leffen", Opponent: "Hungrybox",
+					Match:        models.Match{Id: "11", State: models.MatchStateOpen, Player1Name: "Hungrybox", Player2Name: "TSM | Leffen", SuggestedPlayOrder: 2, Station: "2"},
+					MatchesAhead: 1,
+				},
+				{
+					GameName: "game1", TournamentId: "1", Player: "TSM | Leffen", Opponent: models.PlayerTBD,
+					Match:        models.Match{Id: "12", State: models.MatchStatePending, Player1Name: "TSM | Leffen", Player2Name: models.PlayerTBD, SuggestedPlayOrder: 3},
+					MatchesAhead: 2,
+				},
+			},
+			Errors: []models.TournamentError{},
+		}, gotData)
+		assert.Equal(t, int32(2), mockFetchData.matchCalls.Load())
+	})
+
+	t.Run("It should fall back to partial tags when no participant has the exact tag", func(t *testing.T) {
+		// Given
+		router, _ := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/players/hungry/matches", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.PlayerMatchesResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		require.Len(t, gotData.Matches, 1)
+		assert.Equal(t, "Hungrybox", gotData.Matches[0].Player)
+	})
+
+	t.Run("It should match a name given with a sponsor prefix", func(t *testing.T) {
+		// Given
+		router, _ := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/players/"+url.PathEscape("C9 | Mango")+"/matches", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.PlayerMatchesResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		require.Len(t, gotData.Matches, 1)
+		assert.Equal(t, "Zain", gotData.Matches[0].Opponent)
+	})
+
+	t.Run("It should return no matches for an unknown player", func(t *testing.T) {
+		// Given
+		router, mockFetchData := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/players/nobody/matches", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.PlayerMatchesResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		assert.Empty(t, gotData.Matches)
+		assert.Zero(t, mockFetchData.matchCalls.Load())
+	})
+
+	t.Run("It should reject a name without letters or digits", func(t *testing.T) {
+		// Given
+		router, _ := setup(t)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/players/.../matches", nil))
+		// Then
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
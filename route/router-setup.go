@@ -1,27 +1,73 @@
 package route
 
 import (
-	"net/http"
+	"time"
 
 	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
 	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/watcher"
 	"github.com/go-chi/chi/v5"
 )
 
-func RouterSetup(fetchData challongebracketmatches.FetchData, cache *cache.Cache) *chi.Mux {
-	r := chi.NewRouter()
+type (
+	routerConfig struct {
+		adminToken   string
+		matchWatcher *watcher.Watcher
+		heartbeat    time.Duration
+	}
+
+	RouterOption func(*routerConfig)
+)
 
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`
-		{
-			"status": "UP"
+// WithAdminToken mounts the cache admin endpoints, guarded by token. They are left out when token is empty
+func WithAdminToken(token string) RouterOption {
+	return func(c *routerConfig) {
+		c.adminToken = token
+	}
+}
+
+// WithMatchWatcher mounts the match stream and socket, fed by matchWatcher and written to every heartbeat while idle
+func WithMatchWatcher(matchWatcher *watcher.Watcher, heartbeat time.Duration) RouterOption {
+	return func(c *routerConfig) {
+		if heartbeat <= 0 {
+			heartbeat = DefaultHeartbeat
 		}
-		`))
-	})
+		c.matchWatcher = matchWatcher
+		c.heartbeat = heartbeat
+	}
+}
+
+func RouterSetup(fetchData challongebracketmatches.FetchData, cache *cache.Cache, opts ...RouterOption) *chi.Mux {
+	var config routerConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	r := chi.NewRouter()
+
+	r.Get("/health", GetHealth(cache))
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Get("/matches", GetMatches(fetchData, cache))
+		if config.matchWatcher != nil {
+			r.Get("/matches/stream", GetMatchesStream(config.matchWatcher, config.heartbeat))
+			r.Get("/ws", GetMatchesSocket(config.matchWatcher, config.heartbeat))
+		}
+		r.Get("/tournaments", GetTournaments(fetchData, cache))
+		r.Get("/tournaments/{tournamentId}/matches", GetTournamentMatches(fetchData, cache))
+		r.Get("/tournaments/{tournamentId}/participants", GetTournamentParticipants(fetchData, cache))
+		r.Get("/players/{name}/matches", GetPlayerMatches(fetchData, cache))
+		r.Get("/conflicts", GetConflicts(fetchData, cache))
+
+		if config.adminToken != "" {
+			r.Route("/admin/cache", func(r chi.Router) {
+				r.Use(AdminAuth(config.adminToken))
+				r.Get("/", GetCacheEntries(cache))
+				r.Delete("/", DeleteCache(cache))
+				r.Delete("/dates/{date}", DeleteCacheDate(cache))
+				r.Post("/dates/{date}/refresh", RefreshCacheDate(fetchData, cache))
+				r.Delete("/tournaments/{tournamentId}", DeleteCacheTournament(cache))
+			})
+		}
 	})
 
 	return r
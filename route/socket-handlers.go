@@ -0,0 +1,219 @@
+package route
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/watcher"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/go-chi/httplog/v2"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// socketWriteWait is how long a client has to take a message before it is dropped
+	socketWriteWait = 10 * time.Second
+	// socketReadLimit bounds the subscription requests a client sends
+	socketReadLimit = 4096
+)
+
+// socketUpgrader accepts every origin, like the CORS policy of the rest of the API
+var socketUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// socketSelection is the games and tournaments a websocket client is subscribed to. The reading goroutine changes it
+// while the writing one filters events with it
+type socketSelection struct {
+	mu          sync.RWMutex
+	games       map[string]bool
+	tournaments map[string]bool
+}
+
+// GetMatchesSocket sends the match events of the request's filter over a websocket, for the games and tournaments the
+// client subscribes to. Clients change their subscriptions by sending subscribe and unsubscribe requests, each answered
+// with the matches of what they added. Games in the games query param are subscribed to when the connection opens.
+// A client falling behind the watcher is disconnected rather than holding up the poll
+func GetMatchesSocket(matchWatcher *watcher.Watcher, heartbeat time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := httplog.LogEntry(r.Context())
+
+		requestValues, err := models.CreateRequestValues(r.URL.Query())
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			requestQueryParamErr := ErrorBadRequest(err.Error(), err)
+			requestQueryParamErr.LogError(logger)
+			requestQueryParamErr.JSONError(w)
+			return
+		}
+
+		selection := &socketSelection{games: map[string]bool{}, tournaments: map[string]bool{}}
+		selection.update(models.SubscriptionRequest{Action: models.SocketSubscribe, Games: requestValues.GameList})
+		subscription, err := matchWatcher.Subscribe(r.Context(), requestValues.Filter, "", watcher.View{
+			States: requestValues.States,
+			Select: selection.selects(),
+		})
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			subscribeErr := ErrorFromFetch("Error in getting match data", err)
+			subscribeErr.LogError(logger)
+			subscribeErr.JSONError(w)
+			return
+		}
+		defer subscription.Close()
+
+		conn, err := socketUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader already answered the request
+			logger.Error("Match socket can't be upgraded", "error", err)
+			return
+		}
+
+		replies := make(chan any, 1)
+		replies <- selection.subscribed(logger, *subscription.Snapshot)
+		readDone, writeDone := make(chan struct{}), make(chan struct{})
+		go readSubscriptions(conn, subscription, selection, heartbeat, logger, replies, readDone, writeDone)
+		defer func() {
+			close(writeDone)
+			conn.Close()
+			<-readDone
+		}()
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			var message any
+			select {
+			case <-readDone:
+				return
+			case message = <-replies:
+			case event, ok := <-subscription.Events:
+				if !ok {
+					closeMessage := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "fell behind or server stopping")
+					conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(socketWriteWait))
+					return
+				}
+				// events queued before an unsubscribe
+				if !selection.selected(event.GameName, event.TournamentId) {
+					continue
+				}
+				message = event
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)); err != nil {
+					return
+				}
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+			if err := conn.WriteJSON(message); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readSubscriptions applies the subscription requests of conn until it fails or misses two heartbeats, handing the
+// replies to the writing goroutine. readDone is closed when it returns
+func readSubscriptions(conn *websocket.Conn, subscription *watcher.Subscription, selection *socketSelection, heartbeat time.Duration, logger slog.Logger, replies chan<- any, readDone, writeDone chan struct{}) {
+	defer close(readDone)
+
+	conn.SetReadLimit(socketReadLimit)
+	conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+	})
+	for {
+		var request models.SubscriptionRequest
+		var reply any
+		// read the frame before decoding it, so an empty or truncated request isn't mistaken for a closed connection
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		switch {
+		case json.Unmarshal(data, &request) != nil:
+			reply = models.SocketErrorMessage{Type: models.SocketError, Message: "Subscription request is not valid JSON"}
+		case request.Action != models.SocketSubscribe && request.Action != models.SocketUnsubscribe:
+			reply = models.SocketErrorMessage{Type: models.SocketError, Message: fmt.Sprintf("Unknown action %q", request.Action)}
+		default:
+			selection.update(request)
+			reply = selection.subscribed(logger, subscription.Reselect(selection.selects()))
+		}
+
+		select {
+		case replies <- reply:
+		case <-writeDone:
+			return
+		}
+	}
+}
+
+// update adds or removes the games and tournaments of request
+func (s *socketSelection) update(request models.SubscriptionRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscribed := request.Action == models.SocketSubscribe
+	for _, game := range request.Games {
+		s.games[game] = subscribed
+	}
+	for _, tournamentId := range request.TournamentIds {
+		s.tournaments[tournamentId] = subscribed
+	}
+}
+
+// selected reports whether the tournament is subscribed to, itself or through its game
+func (s *socketSelection) selected(gameName, tournamentId string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.games[gameName] || s.tournaments[tournamentId]
+}
+
+// selects returns a copy of the selection for the watcher, which is not affected by later updates
+func (s *socketSelection) selects() func(gameName, tournamentId string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	games, tournaments := map[string]bool{}, map[string]bool{}
+	for game, subscribed := range s.games {
+		games[game] = subscribed
+	}
+	for tournamentId, subscribed := range s.tournaments {
+		tournaments[tournamentId] = subscribed
+	}
+	return func(gameName, tournamentId string) bool {
+		return games[gameName] || tournaments[tournamentId]
+	}
+}
+
+// subscribed answers a subscription request with the selection and the matches snapshot holds
+func (s *socketSelection) subscribed(logger slog.Logger, snapshot watcher.Snapshot) models.SubscribedMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	message := models.SubscribedMessage{
+		Type:          models.SocketSubscribed,
+		Games:         []string{},
+		TournamentIds: []string{},
+		Snapshot:      snapshotResponse(logger, snapshot),
+	}
+	for game, subscribed := range s.games {
+		if subscribed {
+			message.Games = append(message.Games, game)
+		}
+	}
+	for tournamentId, subscribed := range s.tournaments {
+		if subscribed {
+			message.TournamentIds = append(message.TournamentIds, tournamentId)
+		}
+	}
+	slices.Sort(message.Games)
+	slices.Sort(message.TournamentIds)
+	return message
+}
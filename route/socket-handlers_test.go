@@ -0,0 +1,163 @@
+package route
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/watcher"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMatchesSocket(t *testing.T) {
+	setup := func(t *testing.T) (*httptest.Server, *stubFetchData) {
+		mockFetchData := &stubFetchData{
+			tournaments:  map[string]string{"1": "game1", "2": "game2"},
+			participants: map[string]map[string]string{"1": {"1": "Mango", "2": "Zain"}, "2": {"1": "Armada", "2": "Plup"}},
+			matches: map[string][]models.Match{
+				"1": {{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain"}},
+				"2": {{Id: "20", State: models.MatchStateOpen, Player1Name: "Armada", Player2Name: "Plup"}},
+			},
+		}
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		// every poll fetches again, complete matches included, so the stub's changes are seen straight away
+		mockCache := cache.NewCache(0, 5*time.Hour, logger, cache.WithMaxStaleness(0), cache.WithMatchTTL(0))
+		matchWatcher := watcher.New(mockCache, mockFetchData, 10*time.Millisecond, logger, watcher.WithCompleteInterval(0))
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go matchWatcher.Run(ctx)
+
+		server := httptest.NewServer(RouterSetup(mockFetchData, mockCache, WithMatchWatcher(matchWatcher, 50*time.Millisecond)))
+		t.Cleanup(server.Close)
+		return server, mockFetchData
+	}
+
+	t.Run("It should start with the games of the query and send their events", func(t *testing.T) {
+		// Given
+		server, mockFetchData := setup(t)
+		conn := openSocket(t, server.URL+"/api/v1/ws?date=2006-01-02&games=game1")
+		var subscribed models.SubscribedMessage
+		readSocket(t, conn, &subscribed)
+		// When
+		mockFetchData.setMatches("2", models.Match{Id: "20", State: models.MatchStateOpen, Player1Name: "Armada", Player2Name: "Plup", Underway: true})
+		mockFetchData.setMatches("1", models.Match{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", Underway: true})
+		var gotEvent models.MatchEvent
+		readSocket(t, conn, &gotEvent)
+		// Then
+		assert.Equal(t, models.SocketSubscribed, subscribed.Type)
+		assert.Equal(t, []string{"game1"}, subscribed.Games)
+		require.Len(t, subscribed.Snapshot.Tournaments, 1)
+		assert.Equal(t, "1", subscribed.Snapshot.Tournaments[0].TournamentId)
+		assert.Equal(t, models.MatchEventUnderway, gotEvent.Type)
+		assert.Equal(t, "1", gotEvent.TournamentId)
+	})
+
+	t.Run("It should change what it sends as the client subscribes and unsubscribes", func(t *testing.T) {
+		// Given
+		server, mockFetchData := setup(t)
+		conn := openSocket(t, server.URL+"/api/v1/ws?date=2006-01-02")
+		var opened models.SubscribedMessage
+		readSocket(t, conn, &opened)
+		// When
+		require.NoError(t, conn.WriteJSON(models.SubscriptionRequest{Action: models.SocketSubscribe, Games: []string{"game1"}, TournamentIds: []string{"2"}}))
+		var subscribed models.SubscribedMessage
+		readSocket(t, conn, &subscribed)
+		require.NoError(t, conn.WriteJSON(models.SubscriptionRequest{Action: models.SocketUnsubscribe, Games: []string{"game1"}}))
+		var unsubscribed models.SubscribedMessage
+		readSocket(t, conn, &unsubscribed)
+		mockFetchData.setMatches("1", models.Match{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", Underway: true})
+		mockFetchData.setMatches("2", models.Match{Id: "20", State: models.MatchStateOpen, Player1Name: "Armada", Player2Name: "Plup", Underway: true})
+		var gotEvent models.MatchEvent
+		readSocket(t, conn, &gotEvent)
+		// Then
+		assert.Empty(t, opened.Games)
+		assert.Empty(t, opened.Snapshot.Tournaments, "nothing should be sent before subscribing")
+		assert.Equal(t, []string{"game1"}, subscribed.Games)
+		assert.Equal(t, []string{"2"}, subscribed.TournamentIds)
+		assert.Len(t, subscribed.Snapshot.Tournaments, 2)
+		assert.Empty(t, unsubscribed.Games)
+		assert.Equal(t, []string{"2"}, unsubscribed.TournamentIds)
+		assert.Empty(t, unsubscribed.Snapshot.Tournaments)
+		assert.Equal(t, "2", gotEvent.TournamentId)
+	})
+
+	t.Run("It should answer a request it can't make sense of and stay open", func(t *testing.T) {
+		// Given
+		server, _ := setup(t)
+		conn := openSocket(t, server.URL+"/api/v1/ws?date=2006-01-02")
+		var opened models.SubscribedMessage
+		readSocket(t, conn, &opened)
+		// When
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not json")))
+		var notJSON models.SocketErrorMessage
+		readSocket(t, conn, &notJSON)
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("{")))
+		var truncated models.SocketErrorMessage
+		readSocket(t, conn, &truncated)
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte{}))
+		var empty models.SocketErrorMessage
+		readSocket(t, conn, &empty)
+		require.NoError(t, conn.WriteJSON(models.SubscriptionRequest{Action: "watch"}))
+		var unknownAction models.SocketErrorMessage
+		readSocket(t, conn, &unknownAction)
+		require.NoError(t, conn.WriteJSON(models.SubscriptionRequest{Action: models.SocketSubscribe, Games: []string{"game1"}}))
+		var subscribed models.SubscribedMessage
+		readSocket(t, conn, &subscribed)
+		// Then
+		assert.Equal(t, models.SocketError, notJSON.Type)
+		assert.Equal(t, models.SocketError, truncated.Type)
+		assert.Equal(t, models.SocketError, empty.Type)
+		assert.Equal(t, models.SocketError, unknownAction.Type)
+		assert.Equal(t, `Unknown action "watch"`, unknownAction.Message)
+		assert.Equal(t, []string{"game1"}, subscribed.Games)
+	})
+
+	t.Run("It should reject an unknown match state before upgrading", func(t *testing.T) {
+		// Given
+		server, _ := setup(t)
+		// When
+		_, res, err := websocket.DefaultDialer.Dial(socketURL(server.URL+"/api/v1/ws?date=2006-01-02&state=underway"), nil)
+		// Then
+		require.Error(t, err)
+		require.NotNil(t, res)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("It should not be routed without a match watcher", func(t *testing.T) {
+		// Given
+		router := RouterSetup(&stubFetchData{}, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ws?date=2006-01-02", nil))
+		// Then
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func socketURL(url string) string {
+	return "ws" + strings.TrimPrefix(url, "http")
+}
+
+func openSocket(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(socketURL(url), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readSocket reads the next message into v, failing the test if none comes within a second
+func readSocket(t *testing.T, conn *websocket.Conn, v any) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	require.NoError(t, conn.ReadJSON(v))
+}
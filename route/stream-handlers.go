@@ -0,0 +1,121 @@
+package route
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/watcher"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/go-chi/httplog/v2"
+)
+
+// DefaultHeartbeat is how often a stream with nothing to send is written to, so proxies keep the connection open
+const DefaultHeartbeat = 15 * time.Second
+
+// snapshotEvent names the server-sent event carrying every match, sent when a stream can't resume
+const snapshotEvent = "snapshot"
+
+// GetMatchesStream streams the matches of the request's filter as server-sent events. A snapshot event shaped like the
+// matches endpoint's response comes first, then an event for each match that changes as the watcher notices it.
+// Reconnecting with the Last-Event-ID header sends the events missed instead of a new snapshot, while the watcher
+// still holds them
+func GetMatchesStream(matchWatcher *watcher.Watcher, heartbeat time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := httplog.LogEntry(r.Context())
+
+		requestValues, err := models.CreateRequestValues(r.URL.Query())
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			requestQueryParamErr := ErrorBadRequest(err.Error(), err)
+			requestQueryParamErr.LogError(logger)
+			requestQueryParamErr.JSONError(w)
+			return
+		}
+
+		subscription, err := matchWatcher.Subscribe(r.Context(), requestValues.Filter, r.Header.Get("Last-Event-ID"), watcher.View{
+			States: requestValues.States,
+			Select: selectGames(requestValues.GameList),
+		})
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			subscribeErr := ErrorFromFetch("Error in getting match data", err)
+			subscribeErr.LogError(logger)
+			subscribeErr.JSONError(w)
+			return
+		}
+		defer subscription.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// keeps nginx from buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		if subscription.Snapshot != nil {
+			writeEvent(w, subscription.Snapshot.EventId, snapshotEvent, snapshotResponse(logger, *subscription.Snapshot))
+		}
+		for _, event := range subscription.Missed {
+			writeEvent(w, event.Id, string(event.Type), event)
+		}
+		responseController := http.NewResponseController(w)
+		if err := responseController.Flush(); err != nil {
+			logger.Error("Match stream can't be flushed", "error", err)
+			return
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-subscription.Events:
+				// the client reconnects with the last event it got when it fell behind or the server is stopping
+				if !ok {
+					return
+				}
+				writeEvent(w, event.Id, string(event.Type), event)
+			case <-ticker.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+			if err := responseController.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// selectGames selects the tournaments of gamesList, every tournament when it is empty
+func selectGames(gamesList []string) func(gameName, tournamentId string) bool {
+	if len(gamesList) == 0 {
+		return nil
+	}
+	return func(gameName, tournamentId string) bool {
+		return slices.Contains(gamesList, gameName)
+	}
+}
+
+// snapshotResponse shapes a watcher snapshot like the matches endpoint's response
+func snapshotResponse(logger slog.Logger, snapshot watcher.Snapshot) models.MatchesResponse {
+	return models.MatchesResponse{
+		Tournaments: snapshot.Tournaments,
+		Conflicts:   findConflicts(snapshot.Participants, snapshot.Tournaments),
+		Errors:      tournamentErrors(logger, "Error in getting match data", snapshot.Errors),
+		Partial:     len(snapshot.Errors) > 0,
+	}
+}
+
+// writeEvent writes data as a server-sent event. JSON never spans lines, so it fits a single data field
+func writeEvent(w io.Writer, id, event string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, body)
+	return err
+}
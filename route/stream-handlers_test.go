@@ -0,0 +1,193 @@
+package route
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/watcher"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMatchesStream(t *testing.T) {
+	setup := func(t *testing.T) (*httptest.Server, *stubFetchData) {
+		mockFetchData := &stubFetchData{
+			tournaments:  map[string]string{"1": "game1", "2": "game2"},
+			participants: map[string]map[string]string{"1": {"1": "Mango", "2": "Zain"}, "2": {"1": "Armada", "2": "Plup"}},
+			matches: map[string][]models.Match{
+				"1": {{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain"}},
+				"2": {{Id: "20", State: models.MatchStateOpen, Player1Name: "Armada", Player2Name: "Plup"}},
+			},
+		}
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		// every poll fetches again, complete matches included, so the stub's changes are seen straight away
+		mockCache := cache.NewCache(0, 5*time.Hour, logger, cache.WithMaxStaleness(0), cache.WithMatchTTL(0))
+		matchWatcher := watcher.New(mockCache, mockFetchData, 10*time.Millisecond, logger, watcher.WithCompleteInterval(0))
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go matchWatcher.Run(ctx)
+
+		server := httptest.NewServer(RouterSetup(mockFetchData, mockCache, WithMatchWatcher(matchWatcher, 50*time.Millisecond)))
+		t.Cleanup(server.Close)
+		return server, mockFetchData
+	}
+
+	t.Run("It should send a snapshot and then the matches that changed in the games requested", func(t *testing.T) {
+		// Given
+		server, mockFetchData := setup(t)
+		events := openStream(t, server.URL+"/api/v1/matches/stream?date=2006-01-02&games=game1", "")
+		snapshot := events.nextEvent(t)
+		// When
+		mockFetchData.setMatches("2", models.Match{Id: "20", State: models.MatchStateOpen, Player1Name: "Armada", Player2Name: "Plup", Underway: true})
+		mockFetchData.setMatches("1", models.Match{Id: "10", State: models.MatchStateOpen, Player1Name: "Mango", Player2Name: "Zain", Underway: true})
+		gotEvent := events.nextEvent(t)
+		// Then
+		assert.Equal(t, "snapshot", snapshot.event)
+		var gotSnapshot models.MatchesResponse
+		require.NoError(t, json.Unmarshal([]byte(snapshot.data), &gotSnapshot))
+		require.Len(t, gotSnapshot.Tournaments, 1)
+		assert.Equal(t, "1", gotSnapshot.Tournaments[0].TournamentId)
+
+		assert.Equal(t, string(models.MatchEventUnderway), gotEvent.event)
+		var gotMatchEvent models.MatchEvent
+		require.NoError(t, json.Unmarshal([]byte(gotEvent.data), &gotMatchEvent))
+		assert.Equal(t, gotEvent.id, gotMatchEvent.Id)
+		assert.Equal(t, "1", gotMatchEvent.TournamentId)
+		assert.True(t, gotMatchEvent.Match.Underway)
+	})
+
+	t.Run("It should resume from the Last-Event-ID header with the events missed", func(t *testing.T) {
+		// Given
+		server, mockFetchData := setup(t)
+		events := openStream(t, server.URL+"/api/v1/matches/stream?date=2006-01-02", "")
+		snapshot := events.nextEvent(t)
+		events.close()
+		mockFetchData.setMatches("1", models.Match{Id: "10", State: models.MatchStateComplete, Player1Name: "Mango", Player2Name: "Zain", Winner: 1})
+		time.Sleep(20 * time.Millisecond)
+		// When
+		resumed := openStream(t, server.URL+"/api/v1/matches/stream?date=2006-01-02", snapshot.id)
+		gotEvent := resumed.nextEvent(t)
+		// Then
+		assert.Equal(t, string(models.MatchEventCompleted), gotEvent.event)
+	})
+
+	t.Run("It should send heartbeats while nothing changes", func(t *testing.T) {
+		// Given
+		server, _ := setup(t)
+		events := openStream(t, server.URL+"/api/v1/matches/stream?date=2006-01-02", "")
+		events.next(t)
+		// When
+		gotEvent := events.next(t)
+		// Then
+		assert.Equal(t, "heartbeat", gotEvent.comment)
+	})
+
+	t.Run("It should reject an unknown match state", func(t *testing.T) {
+		// Given
+		server, _ := setup(t)
+		// When
+		res, err := http.Get(server.URL + "/api/v1/matches/stream?date=2006-01-02&state=underway")
+		// Then
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("It should not be routed without a match watcher", func(t *testing.T) {
+		// Given
+		router := RouterSetup(&stubFetchData{}, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/matches/stream?date=2006-01-02", nil))
+		// Then
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// serverSentEvent is an event read off a stream, or a comment
+type serverSentEvent struct {
+	id, event, data, comment string
+}
+
+type eventStream struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+func openStream(t *testing.T, url, lastEventId string) *eventStream {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	stream := &eventStream{body: res.Body, reader: bufio.NewReader(res.Body)}
+	t.Cleanup(stream.close)
+	return stream
+}
+
+// next reads the next event or comment, failing the test if none comes within a second
+func (s *eventStream) next(t *testing.T) serverSentEvent {
+	t.Helper()
+	read := make(chan serverSentEvent, 1)
+	go func() {
+		var event serverSentEvent
+		for {
+			line, err := s.reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				read <- event
+				return
+			}
+			field, value, _ := strings.Cut(line, ": ")
+			switch field {
+			case "id":
+				event.id = value
+			case "event":
+				event.event = value
+			case "data":
+				event.data = value
+			case "":
+				event.comment = value
+			}
+		}
+	}()
+	select {
+	case event := <-read:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event was streamed")
+		return serverSentEvent{}
+	}
+}
+
+// nextEvent reads the next event, skipping heartbeats
+func (s *eventStream) nextEvent(t *testing.T) serverSentEvent {
+	t.Helper()
+	for {
+		if event := s.next(t); event.comment == "" {
+			return event
+		}
+	}
+}
+
+func (s *eventStream) close() {
+	s.body.Close()
+}
@@ -0,0 +1,70 @@
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httplog/v2"
+)
+
+// GetTournamentParticipants returns the participants of a single tournament
+func GetTournamentParticipants(fetchData challongebracketmatches.FetchData, cache *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := httplog.LogEntry(r.Context())
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if cache.ShouldClearCacheData() {
+			cache.ClearCache()
+		}
+
+		participants, err := cache.GetParticipants(r.Context(), chi.URLParam(r, "tournamentId"), fetchData)
+		if err != nil {
+			participantsErr := ErrorFromFetch("Error in getting tournament data", err)
+			participantsErr.LogError(logger)
+			participantsErr.JSONError(w)
+			return
+		}
+
+		json.NewEncoder(w).Encode(participants)
+	}
+}
+
+// GetTournamentMatches returns the matches of a single tournament in the states given by the state query parameter
+func GetTournamentMatches(fetchData challongebracketmatches.FetchData, cache *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := httplog.LogEntry(r.Context())
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if cache.ShouldClearCacheData() {
+			cache.ClearCache()
+		}
+
+		states, err := models.ParseMatchStates(r.URL.Query()["state"])
+		if err != nil {
+			requestQueryParamErr := ErrorBadRequest(err.Error(), err)
+			requestQueryParamErr.LogError(logger)
+			requestQueryParamErr.JSONError(w)
+			return
+		}
+
+		participants, err := cache.GetParticipants(r.Context(), chi.URLParam(r, "tournamentId"), fetchData)
+		if err != nil {
+			participantsErr := ErrorFromFetch("Error in getting tournament data", err)
+			participantsErr.LogError(logger)
+			participantsErr.JSONError(w)
+			return
+		}
+
+		matches, err := cache.GetMatches(r.Context(), participants, states, fetchData)
+		if err != nil {
+			getMatchesErr := ErrorFromFetch("Error in getting match data", err)
+			getMatchesErr.LogError(logger)
+			getMatchesErr.JSONError(w)
+			return
+		}
+
+		json.NewEncoder(w).Encode(matches)
+	}
+}
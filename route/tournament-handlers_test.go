@@ -0,0 +1,155 @@
+package route
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	challongebracketmatches "github.com/MarcBernstein0/pending-matches/challonge-bracket-matches"
+	"github.com/MarcBernstein0/pending-matches/challonge-bracket-matches/cache"
+	"github.com/MarcBernstein0/pending-matches/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTournamentMatches(t *testing.T) {
+	newFetchData := func() *stubFetchData {
+		return &stubFetchData{
+			tournaments:  map[string]string{"1": "game1", "2": "game2", "3": "game3", "4": "game4"},
+			participants: map[string]map[string]string{"1": {"1": "testName1"}},
+			failingRosters: map[string]error{
+				"3": &challongebracketmatches.APIError{StatusCode: http.StatusNotFound, TournamentID: "3"},
+			},
+			matches: map[string][]models.Match{
+				"1": {
+					{Id: "10", State: models.MatchStateOpen, Player1Name: "testName1"},
+					{Id: "11", State: models.MatchStatePending},
+				},
+				"2": {{Id: "20", State: models.MatchStateOpen}},
+			},
+			failingMatches: map[string]error{
+				"4": &challongebracketmatches.APIError{StatusCode: http.StatusNotFound, TournamentID: "4"},
+			},
+		}
+	}
+
+	t.Run("It should only fetch the matches of the requested tournament", func(t *testing.T) {
+		// Given
+		mockFetchData := newFetchData()
+		router := RouterSetup(mockFetchData, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tournaments/1/matches", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.TournamentMatches
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		assert.Equal(t, models.TournamentMatches{
+			GameName:     "game1",
+			TournamentId: "1",
+			MatchList:    []models.Match{{Id: "10", State: models.MatchStateOpen, Player1Name: "testName1"}},
+		}, gotData)
+		assert.Equal(t, int32(1), mockFetchData.matchCalls.Load())
+	})
+
+	t.Run("It should reuse the roster cached by the matches endpoint", func(t *testing.T) {
+		// Given
+		mockFetchData := newFetchData()
+		mockCache := cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default())
+		require.NoError(t, mockCache.UpdateCache(context.Background(), models.DateFilter("2006-01-02"), mockFetchData))
+		mockFetchData.participants = nil
+		router := RouterSetup(mockFetchData, mockCache)
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tournaments/1/matches?state=open,pending", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.TournamentMatches
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		assert.Equal(t, "game1", gotData.GameName)
+		assert.Len(t, gotData.MatchList, 2)
+	})
+
+	t.Run("It should return not found for a tournament missing from Challonge", func(t *testing.T) {
+		// Given
+		router := RouterSetup(newFetchData(), cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tournaments/3/matches", nil))
+		// Then
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("It should return not found for a tournament Challonge does not know", func(t *testing.T) {
+		// Given
+		mockFetchData := newFetchData()
+		router := RouterSetup(mockFetchData, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tournaments/5/matches", nil))
+		// Then
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, int32(0), mockFetchData.matchCalls.Load())
+	})
+
+	t.Run("It should return the error of a failed match fetch", func(t *testing.T) {
+		// Given
+		router := RouterSetup(newFetchData(), cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tournaments/4/matches", nil))
+		// Then
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("It should reject an unknown match state", func(t *testing.T) {
+		// Given
+		router := RouterSetup(newFetchData(), cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tournaments/1/matches?state=underway", nil))
+		// Then
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestGetTournamentParticipants(t *testing.T) {
+	mockFetchData := &stubFetchData{
+		tournaments:  map[string]string{"1": "game1", "3": "game3"},
+		participants: map[string]map[string]string{"1": {"1": "testName1", "2": "testName2"}},
+		failingRosters: map[string]error{
+			"3": &challongebracketmatches.APIError{StatusCode: http.StatusNotFound, TournamentID: "3"},
+		},
+	}
+
+	t.Run("It should return the participants of the requested tournament", func(t *testing.T) {
+		// Given
+		router := RouterSetup(mockFetchData, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tournaments/1/participants", nil))
+		// Then
+		require.Equal(t, http.StatusOK, w.Code)
+		var gotData models.TournamentParticipants
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&gotData))
+		assert.Equal(t, models.TournamentParticipants{
+			GameName:     "game1",
+			TournamentID: "1",
+			Participant:  map[string]string{"1": "testName1", "2": "testName2"},
+		}, gotData)
+	})
+
+	t.Run("It should return not found for a tournament missing from Challonge", func(t *testing.T) {
+		// Given
+		router := RouterSetup(mockFetchData, cache.NewCache(5*time.Minute, 5*time.Hour, slog.Default()))
+		// When
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tournaments/3/participants", nil))
+		// Then
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}